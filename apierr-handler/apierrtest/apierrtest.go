@@ -0,0 +1,168 @@
+// Package apierrtest provides helpers to test handlers that send APIErrors.
+package apierrtest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iris-contrib/httpexpect/v2"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+)
+
+// EnvelopeSchema is JSON Schema of the APIError response.
+const EnvelopeSchema = `{
+	"type": "object",
+	"properties": {
+		"error": {
+			"type": "object",
+			"properties": {
+				"id":        {"type": "string"},
+				"message":   {"type": "string"}
+			},
+			"required": ["id", "message"]
+		},
+		"meta": {"type": "object"}
+	},
+	"required": ["error"]
+}`
+
+// ValidationSchema is JSON Schema of the validation failed response.
+const ValidationSchema = `{
+	"type": "object",
+	"properties": {
+		"error": {
+			"type": "object",
+			"properties": {
+				"id":        {"type": "string"},
+				"message":   {"type": "string"}
+			},
+			"required": ["id", "message"]
+		},
+		"meta": {
+			"type": "object",
+			"properties": {
+				"errors":  {
+					"type": "array",
+					"items": {
+						"type": "object",
+						"properties": {
+							"field":     {"type": "string"},
+							"message":   {"type": "string"}
+						},
+						"required": ["field", "message"]
+					}
+				}
+			},
+			"required": ["errors"]
+		}
+	},
+	"required": ["error", "meta"]
+}`
+
+// flushTimeout to wait for the queued reports.
+const flushTimeout = 5 * time.Second
+
+// Recorder keeps reports in memory.
+type Recorder struct {
+	mu      sync.Mutex
+	reports []*handler.Report
+}
+
+// Report saves the report.
+func (r *Recorder) Report(report *handler.Report) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reports = append(r.reports, report)
+
+	return nil
+}
+
+// Reports returns recorded reports.
+func (r *Recorder) Reports() []*handler.Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*handler.Report(nil), r.reports...)
+}
+
+// Reset forgets recorded reports.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reports = nil
+}
+
+// Handler preconfigured for tests.
+type Handler struct {
+	*handler.Handler
+	Recorder *Recorder
+}
+
+// NewHandler makes handler that records its reports.
+// Missing getters default to production without debug.
+// Handler is closed when the test finishes.
+func NewHandler(t testing.TB, cfg handler.Config) *Handler {
+	if cfg.EnvGetter == nil {
+		cfg.EnvGetter = func() string {
+			return "production"
+		}
+	}
+	if cfg.DebugGetter == nil {
+		cfg.DebugGetter = func() bool {
+			return false
+		}
+	}
+
+	recorder := &Recorder{}
+	// Don't write into the caller's slice.
+	cfg.Reporters = append(append([]handler.Reporter{}, cfg.Reporters...), recorder)
+
+	h := &Handler{
+		Handler:  handler.New(cfg),
+		Recorder: recorder,
+	}
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		defer cancel()
+
+		h.Close(ctx)
+	})
+
+	return h
+}
+
+// Reports waits for the queued reports and returns them.
+func (h *Handler) Reports() []*handler.Report {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	h.Flush(ctx)
+
+	return h.Recorder.Reports()
+}
+
+// AssertAPIError checks that response is APIError with given id and status.
+func AssertAPIError(t testing.TB, resp *httpexpect.Response, id string, code int) {
+	t.Helper()
+
+	resp.Status(code)
+	resp.JSON().Schema(EnvelopeSchema)
+	resp.JSON().Object().Value("error").Object().ValueEqual("id", id)
+}
+
+// AssertValidationError checks that response is validation failed error with given field error.
+func AssertValidationError(t testing.TB, resp *httpexpect.Response, field string, message string) {
+	t.Helper()
+
+	resp.Status(422)
+	resp.JSON().Schema(ValidationSchema)
+	resp.JSON().Object().Value("meta").Object().Value("errors").Array().Contains(map[string]interface{}{
+		"field":   field,
+		"message": message,
+	})
+}
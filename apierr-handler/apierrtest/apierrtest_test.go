@@ -0,0 +1,38 @@
+package apierrtest_test
+
+import (
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+)
+
+func TestItRecordsReports(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		panic("Error")
+	})
+	api.Get("/missing", func(ctx iris.Context) {
+		panic(apierr.NotFound)
+	})
+
+	e := httptest.New(t, api)
+	apierrtest.AssertAPIError(t, e.GET("/").Expect(), "internal_server_error", iris.StatusInternalServerError)
+	apierrtest.AssertAPIError(t, e.GET("/missing").Expect(), "not_found", iris.StatusNotFound)
+
+	reports := errorsHandler.Reports()
+	if len(reports) != 1 {
+		t.Fatal("Expected 1 report, got", len(reports))
+	}
+	if reports[0].Error.Body.ID != "internal_server_error" {
+		t.Error("Unexpected report", reports[0])
+	}
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/mlanin/go-apierr"
+	"github.com/mlanin/iris-middlewares/internal/adapter"
+	"github.com/mlanin/iris-middlewares/internal/clientip"
+)
+
+// AuditEntry of the authentication or authorization failure.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Status    int       `json:"status"`
+	ErrorID   string    `json:"error_id"`
+	Method    string    `json:"method"`
+	URL       string    `json:"url"`
+	Route     string    `json:"route"`
+	ClientIP  string    `json:"client_ip"`
+	UserAgent string    `json:"user_agent"`
+	User      string    `json:"user,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// AuditSink stores audit entries. It should only ever append.
+type AuditSink interface {
+	Write(entry *AuditEntry) error
+}
+
+// AuditConfig for the security audit log.
+type AuditConfig struct {
+	// Where to write entries. Audit is off if nil.
+	Sink AuditSink
+	// Routes, like "/admin/{id}", or URL prefixes, like "/admin/", where 404 errors are audited too.
+	SensitiveRoutes []string
+	// Returns identifier of the authenticated user. Iris context is nil for net/http requests.
+	UserGetter func(ctx iris.Context, r *http.Request) string
+}
+
+// Sink appending JSON lines to the file.
+type fileAuditSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileAuditSink makes sink that appends entries to the file as JSON lines.
+func NewFileAuditSink(path string) (AuditSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileAuditSink{file: file}, nil
+}
+
+// Write entry to the file.
+func (s *fileAuditSink) Write(entry *AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.file.Write(append(line, '\n'))
+
+	return err
+}
+
+// Write audit entry for the security related errors.
+func (h *Handler) audit(fail *apierr.APIError, c adapter.Context) {
+	if h.Config.Audit.Sink == nil || !h.needToAudit(fail, c) {
+		return
+	}
+
+	r := c.Request()
+	entry := &AuditEntry{
+		Time:      time.Now(),
+		Status:    fail.HTTPCode,
+		ErrorID:   fail.Body.ID,
+		Method:    r.Method,
+		URL:       r.URL.String(),
+		Route:     c.Route(),
+		ClientIP:  h.clientIP(r),
+		UserAgent: r.UserAgent(),
+		RequestID: c.RequestID(),
+	}
+
+	if getter := h.Config.Audit.UserGetter; getter != nil {
+		h.safely("audit user getter", c, func() {
+			entry.User = getter(c.Iris(), r)
+		})
+	}
+
+	if err := h.Config.Audit.Sink.Write(entry); err != nil {
+		c.Log("[apierr-handler] can't write audit entry: " + err.Error())
+	}
+}
+
+// Check if error must be audited.
+func (h *Handler) needToAudit(fail *apierr.APIError, c adapter.Context) bool {
+	switch fail.HTTPCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	case http.StatusNotFound:
+		return h.isSensitiveRoute(c)
+	}
+
+	return false
+}
+
+// Check if 404 on this route must be audited.
+func (h *Handler) isSensitiveRoute(c adapter.Context) bool {
+	route := c.Route()
+	path := c.Request().URL.Path
+
+	for _, sensitive := range h.Config.Audit.SensitiveRoutes {
+		if route == sensitive || strings.HasPrefix(path, sensitive) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolve client IP honoring trusted proxies.
+func (h *Handler) clientIP(r *http.Request) string {
+	return clientip.Resolve(r, h.trustedProxies)
+}
@@ -0,0 +1,116 @@
+package handler_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+)
+
+type memoryAuditSink struct {
+	mu      sync.Mutex
+	entries []*handler.AuditEntry
+}
+
+func (s *memoryAuditSink) Write(entry *handler.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, entry)
+
+	return nil
+}
+
+func TestItAuditsSecurityErrors(t *testing.T) {
+	api := iris.New()
+
+	sink := &memoryAuditSink{}
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		Audit: handler.AuditConfig{
+			Sink:            sink,
+			SensitiveRoutes: []string{"/admin/"},
+			UserGetter: func(ctx iris.Context, r *http.Request) string {
+				return r.Header.Get("X-User")
+			},
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/forbidden", func(ctx iris.Context) {
+		panic(&apierr.APIError{
+			Body: apierr.Body{
+				ID:      "forbidden",
+				Message: "Forbidden.",
+			},
+			HTTPCode: http.StatusForbidden,
+		})
+	})
+	api.Get("/admin/users", func(ctx iris.Context) {
+		panic(apierr.NotFound)
+	})
+	api.Get("/news", func(ctx iris.Context) {
+		panic(apierr.NotFound)
+	})
+
+	e := httptest.New(t, api)
+	e.GET("/forbidden").WithHeader("X-User", "john").WithHeader("User-Agent", "test").
+		WithTransformer(func(r *http.Request) {
+			// Test binder doesn't set the peer address.
+			r.RemoteAddr = "192.0.2.1:1234"
+		}).
+		Expect().
+		Status(http.StatusForbidden)
+	e.GET("/admin/users").Expect().Status(http.StatusNotFound)
+	e.GET("/news").Expect().Status(http.StatusNotFound)
+
+	if len(sink.entries) != 2 {
+		t.Fatal("Expected 2 audit entries, got", len(sink.entries))
+	}
+
+	entry := sink.entries[0]
+	if entry.Status != http.StatusForbidden || entry.User != "john" || entry.UserAgent != "test" || entry.Route != "/forbidden" || entry.ClientIP != "192.0.2.1" {
+		t.Error("Unexpected audit entry", entry)
+	}
+
+	if len(errorsHandler.Reports()) != 0 {
+		t.Error("Expected audit to be independent of reporting")
+	}
+}
+
+func TestItDoesNotAuditInjectedErrors(t *testing.T) {
+	api := iris.New()
+
+	sink := &memoryAuditSink{}
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		Audit: handler.AuditConfig{Sink: sink},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/login", func(ctx iris.Context) {
+		errorsHandler.Inject(ctx, &apierr.APIError{
+			Body:     apierr.Body{ID: "unauthorized", Message: "Unauthorized."},
+			HTTPCode: http.StatusUnauthorized,
+		})
+	})
+	api.Get("/admin", func(ctx iris.Context) {
+		errorsHandler.Inject(ctx, &apierr.APIError{
+			Body:     apierr.Body{ID: "forbidden", Message: "Forbidden."},
+			HTTPCode: http.StatusForbidden,
+		})
+	})
+
+	e := httptest.New(t, api)
+	e.GET("/login").Expect().Status(http.StatusUnauthorized)
+	e.GET("/admin").Expect().Status(http.StatusForbidden)
+
+	if len(sink.entries) != 0 {
+		t.Error("Expected injected errors not to be audited, got", len(sink.entries))
+	}
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// BreakerState of the reporter circuit.
+type BreakerState int
+
+const (
+	// BreakerClosed sends reports to the reporter.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen sends reports to the fallback.
+	BreakerOpen
+	// BreakerHalfOpen lets one probe report through to check the reporter.
+	BreakerHalfOpen
+)
+
+const (
+	defaultBreakerThreshold    = 5
+	defaultBreakerOpenDuration = 30 * time.Second
+)
+
+// String representation of the state.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+
+	return "closed"
+}
+
+// BreakerConfig for the circuit breakers wrapping every reporter.
+type BreakerConfig struct {
+	// Consecutive failures to open the circuit. Defaults to 5.
+	Threshold int
+	// How long the circuit stays open before the probe. Defaults to 30 seconds.
+	OpenDuration time.Duration
+	// Reporter used while the circuit is open. Defaults to stderr.
+	Fallback Reporter
+}
+
+// FileReporter appends messages to the file.
+type FileReporter struct {
+	Reporter
+
+	file *os.File
+}
+
+// NewFileReporter makes reporter that appends messages to the file.
+// Close it on application shutdown.
+func NewFileReporter(path string) (*FileReporter, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileReporter{
+		Reporter: NewLogReporter(log.New(file, "", log.LstdFlags)),
+		file:     file,
+	}, nil
+}
+
+// Close the file.
+func (r *FileReporter) Close() error {
+	return r.file.Close()
+}
+
+// Circuit breaker around the reporter.
+type breaker struct {
+	name         string
+	reporter     Reporter
+	fallback     Reporter
+	threshold    int
+	openDuration time.Duration
+	logger       *log.Logger
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// Changes with every state, so late results of old calls are ignored.
+	generation uint64
+}
+
+// Wrap reporter into the circuit breaker.
+func newBreaker(name string, reporter Reporter, cfg BreakerConfig, logger *log.Logger) *breaker {
+	b := &breaker{
+		name:         name,
+		reporter:     reporter,
+		fallback:     cfg.Fallback,
+		threshold:    cfg.Threshold,
+		openDuration: cfg.OpenDuration,
+		logger:       logger,
+	}
+
+	if b.fallback == nil {
+		b.fallback = NewLogReporter(log.New(os.Stderr, "", log.LstdFlags))
+	}
+	if b.threshold <= 0 {
+		b.threshold = defaultBreakerThreshold
+	}
+	if b.openDuration <= 0 {
+		b.openDuration = defaultBreakerOpenDuration
+	}
+
+	return b
+}
+
+// Report to the reporter or to the fallback if the circuit is open.
+func (b *breaker) Report(report *Report) error {
+	generation, ok := b.allow()
+	if !ok {
+		return b.fallback.Report(report)
+	}
+
+	err := b.call(report)
+	b.record(generation, err)
+
+	if err != nil {
+		// Don't lose the report that failed.
+		if fallbackErr := b.fallback.Report(report); fallbackErr != nil {
+			b.logger.Printf("%s fallback failed: %v", b.name, fallbackErr)
+		}
+	}
+
+	return err
+}
+
+// Call reporter treating its panic as failure.
+func (b *breaker) call(report *Report) (err error) {
+	defer func() {
+		if fail := recover(); fail != nil {
+			err = fmt.Errorf("reporter panicked: %+v", fail)
+		}
+	}()
+
+	return b.reporter.Report(report)
+}
+
+// Check if report can be sent to the reporter.
+// Returns generation of the state the call starts in.
+func (b *breaker) allow() (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.openDuration {
+			return 0, false
+		}
+		b.setState(BreakerHalfOpen)
+		return b.generation, true
+	case BreakerHalfOpen:
+		// Probe is already in flight.
+		return 0, false
+	}
+
+	return b.generation, true
+}
+
+// Record the result of the call.
+func (b *breaker) record(generation uint64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Call started before the state changed, its result says nothing about the current one.
+	if generation != b.generation {
+		return
+	}
+
+	if err == nil {
+		b.failures = 0
+		b.setState(BreakerClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		b.setState(BreakerOpen)
+	}
+}
+
+// Change state and log it.
+func (b *breaker) setState(state BreakerState) {
+	if b.state == state {
+		return
+	}
+
+	b.logger.Printf("%s circuit changed from %s to %s", b.name, b.state, state)
+	b.state = state
+	b.generation++
+}
@@ -0,0 +1,70 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+)
+
+func TestItOpensCircuitForFailingReporter(t *testing.T) {
+	api := iris.New()
+
+	var calls, fallbacks int32
+
+	errorsHandler := handler.New(handler.Config{
+		EnvGetter: func() string {
+			return "production"
+		},
+		DebugGetter: func() bool {
+			return false
+		},
+		Reporters: []handler.Reporter{
+			handler.ReporterFunc(func(report *handler.Report) error {
+				atomic.AddInt32(&calls, 1)
+				return errors.New("Sink is down")
+			}),
+		},
+		Workers: 1,
+		Breaker: handler.BreakerConfig{
+			Threshold:    2,
+			OpenDuration: time.Hour,
+			Fallback: handler.ReporterFunc(func(report *handler.Report) error {
+				atomic.AddInt32(&fallbacks, 1)
+				return nil
+			}),
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		panic("Error")
+	})
+
+	e := httptest.New(t, api)
+	for i := 0; i < 4; i++ {
+		e.GET("/").
+			Expect().
+			Status(iris.StatusInternalServerError)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := errorsHandler.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 2 {
+		t.Error("Expected reporter to be called 2 times, got", calls)
+	}
+	if fallbacks != 4 {
+		t.Error("Expected fallback to be called 4 times, got", fallbacks)
+	}
+}
@@ -0,0 +1,355 @@
+package handler
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/mlanin/iris-middlewares/internal/adapter"
+)
+
+const (
+	defaultBudgetWindow      = time.Hour
+	defaultBudgetBuckets     = 60
+	defaultBudgetMaxBurnRate = 1
+	defaultBudgetMinRequests = 20
+)
+
+// Statuses of the error budget.
+const (
+	BudgetHealthy  = "healthy"
+	BudgetDegraded = "degraded"
+)
+
+// BudgetConfig for the error budget tracking.
+type BudgetConfig struct {
+	// Default SLO target, like 0.999. Tracking is off if zero.
+	// Targets must be between 0 and 1, others are logged and ignored.
+	Objective float64
+	// SLO targets of the routes, like "GET /users/{id}".
+	Routes map[string]float64
+	// Rolling window of the counts. Defaults to 1 hour.
+	Window time.Duration
+	// Number of buckets in the window. Defaults to 60.
+	Buckets int
+	// Burn rate from which the route is degraded. Defaults to 1.
+	MaxBurnRate float64
+	// Min number of requests in the window to judge the route. Defaults to 20.
+	MinRequests int
+}
+
+// BudgetStatus of all tracked routes.
+type BudgetStatus struct {
+	Status string        `json:"status"`
+	Routes []RouteBudget `json:"routes"`
+}
+
+// RouteBudget is the error budget of the single route.
+type RouteBudget struct {
+	Route     string  `json:"route"`
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	Objective float64 `json:"objective"`
+	BurnRate  float64 `json:"burn_rate"`
+	Status    string  `json:"status"`
+}
+
+// Counts of the single bucket.
+type budgetBucket struct {
+	slot     int64
+	requests int
+	errors   int
+}
+
+// Keeps rolling window counts of the routes.
+type budget struct {
+	config BudgetConfig
+	width  time.Duration
+
+	mu     sync.Mutex
+	routes map[string][]budgetBucket
+}
+
+// Make budget if it is configured.
+func newBudget(config BudgetConfig, logger *log.Logger) *budget {
+	if config.Objective != 0 && !validObjective(config.Objective) {
+		logger.Printf("budget objective %v is ignored, it must be between 0 and 1", config.Objective)
+		config.Objective = 0
+	}
+
+	routes := make(map[string]float64, len(config.Routes))
+	for route, objective := range config.Routes {
+		if !validObjective(objective) {
+			logger.Printf("budget objective %v of %s is ignored, it must be between 0 and 1", objective, route)
+			continue
+		}
+		routes[route] = objective
+	}
+	config.Routes = routes
+
+	if config.Objective == 0 && len(config.Routes) == 0 {
+		return nil
+	}
+	if config.Window <= 0 {
+		config.Window = defaultBudgetWindow
+	}
+	if config.Buckets <= 0 {
+		config.Buckets = defaultBudgetBuckets
+	}
+	if config.MaxBurnRate <= 0 {
+		config.MaxBurnRate = defaultBudgetMaxBurnRate
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = defaultBudgetMinRequests
+	}
+
+	return &budget{
+		config: config,
+		width:  config.Window / time.Duration(config.Buckets),
+		routes: make(map[string][]budgetBucket),
+	}
+}
+
+// Check that SLO target leaves some budget to burn.
+func validObjective(objective float64) bool {
+	return objective > 0 && objective < 1
+}
+
+// Count the response of the route.
+func (b *budget) record(route string, status int, now time.Time) {
+	if route == "" {
+		return
+	}
+
+	slot := now.UnixNano() / int64(b.width)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	buckets, ok := b.routes[route]
+	if !ok {
+		buckets = make([]budgetBucket, b.config.Buckets)
+		b.routes[route] = buckets
+	}
+
+	bucket := &buckets[slot%int64(len(buckets))]
+	if bucket.slot != slot {
+		*bucket = budgetBucket{slot: slot}
+	}
+
+	bucket.requests++
+	if status >= http.StatusInternalServerError {
+		bucket.errors++
+	}
+}
+
+// Compute burn rates of all routes.
+func (b *budget) status(now time.Time) BudgetStatus {
+	current := now.UnixNano() / int64(b.width)
+	oldest := current - int64(b.config.Buckets) + 1
+
+	result := BudgetStatus{
+		Status: BudgetHealthy,
+		Routes: make([]RouteBudget, 0),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for route, buckets := range b.routes {
+		objective := b.objective(route)
+		if !validObjective(objective) {
+			continue
+		}
+
+		item := RouteBudget{
+			Route:     route,
+			Objective: objective,
+			Status:    BudgetHealthy,
+		}
+
+		for _, bucket := range buckets {
+			if bucket.slot >= oldest && bucket.slot <= current {
+				item.Requests += bucket.requests
+				item.Errors += bucket.errors
+			}
+		}
+
+		if item.Requests > 0 {
+			item.ErrorRate = float64(item.Errors) / float64(item.Requests)
+			item.BurnRate = item.ErrorRate / (1 - objective)
+		}
+		if item.Requests >= b.config.MinRequests && item.BurnRate >= b.config.MaxBurnRate {
+			item.Status = BudgetDegraded
+			result.Status = BudgetDegraded
+		}
+
+		result.Routes = append(result.Routes, item)
+	}
+
+	sort.Slice(result.Routes, func(i, j int) bool {
+		return result.Routes[i].Route < result.Routes[j].Route
+	})
+
+	return result
+}
+
+// SLO target of the route.
+func (b *budget) objective(route string) float64 {
+	if objective, ok := b.config.Routes[route]; ok {
+		return objective
+	}
+
+	return b.config.Objective
+}
+
+// Make route name with the method, like "GET /users/{id}".
+func budgetRoute(method string, route string) string {
+	if route == "" || strings.Contains(route, " ") {
+		return route
+	}
+
+	return method + " " + route
+}
+
+// Budget returns burn rates of the tracked routes.
+// Node is degraded if any route burns its budget too fast.
+func (h *Handler) Budget() BudgetStatus {
+	if h.budget == nil {
+		return BudgetStatus{Status: BudgetHealthy, Routes: make([]RouteBudget, 0)}
+	}
+
+	return h.budget.status(time.Now())
+}
+
+// ServeBudget is the health endpoint with the error budget.
+// Answers 503 when the node is degraded. Requests of the endpoint are not tracked.
+func (h *Handler) ServeBudget(ctx iris.Context) {
+	Untrack(ctx)
+
+	status := h.Budget()
+
+	code := http.StatusOK
+	if status.Status == BudgetDegraded {
+		code = http.StatusServiceUnavailable
+	}
+
+	ctx.StatusCode(code)
+	ctx.JSON(status)
+}
+
+// BudgetHTTP is the health endpoint with the error budget for net/http.
+func (h *Handler) BudgetHTTP(w http.ResponseWriter, r *http.Request) {
+	UntrackHTTP(w)
+
+	status := h.Budget()
+
+	code := http.StatusOK
+	if status.Status == BudgetDegraded {
+		code = http.StatusServiceUnavailable
+	}
+
+	adapter.FromHTTP(w, r, h.Config.Logger).JSON(code, status)
+}
+
+// Count the Iris response in the budget and lockout.
+func (h *Handler) track(ctx iris.Context, key string) {
+	if ctx.Values().GetBoolDefault(untrackedKey, false) {
+		return
+	}
+
+	h.countFailure(key, ctx.GetStatusCode())
+
+	if h.budget == nil {
+		return
+	}
+
+	route := ""
+	if current := ctx.GetCurrentRoute(); current != nil {
+		route = current.Path()
+	}
+
+	h.budget.record(budgetRoute(ctx.Method(), route), ctx.GetStatusCode(), time.Now())
+}
+
+// Count the net/http response in the budget and lockout.
+func (h *Handler) trackHTTP(w *statusRecorder, r *http.Request, key string) {
+	if w.untracked {
+		return
+	}
+
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	h.countFailure(key, status)
+
+	if h.budget != nil {
+		h.budget.record(budgetRoute(r.Method, r.Pattern), status, time.Now())
+	}
+}
+
+// Remembers the status of net/http response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	// Set for the requests excluded from the budget and lockout.
+	untracked bool
+}
+
+// Find the recorder under the writers of other middlewares.
+func findStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	for w != nil {
+		if recorder, ok := w.(*statusRecorder); ok {
+			return recorder
+		}
+
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = unwrapper.Unwrap()
+	}
+
+	return nil
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Unwrap lets http.ResponseController reach the original writer.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush lets streaming handlers, like server-sent events, check for http.Flusher.
+func (w *statusRecorder) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack lets websocket handlers check for http.Hijacker.
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
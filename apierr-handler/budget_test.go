@@ -0,0 +1,167 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	irishttptest "github.com/kataras/iris/v12/httptest"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+)
+
+func TestItTracksErrorBudget(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		Budget: handler.BudgetConfig{
+			Objective:   0.99,
+			MinRequests: 4,
+			Routes: map[string]float64{
+				"GET /flaky": 0.5,
+			},
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	fail := false
+	api.Get("/ok", func(ctx iris.Context) {
+		ctx.WriteString("ok")
+	})
+	api.Get("/flaky", func(ctx iris.Context) {
+		fail = !fail
+		if fail {
+			panic("Error")
+		}
+	})
+	api.Get("/health", errorsHandler.ServeBudget)
+
+	e := irishttptest.New(t, api)
+
+	for i := 0; i < 4; i++ {
+		e.GET("/ok").Expect().Status(iris.StatusOK)
+		e.GET("/flaky").Expect()
+	}
+
+	status := errorsHandler.Budget()
+	if status.Status != handler.BudgetDegraded {
+		t.Fatal("Expected degraded status, got", status.Status)
+	}
+
+	routes := map[string]handler.RouteBudget{}
+	for _, route := range status.Routes {
+		routes[route.Route] = route
+	}
+
+	flaky := routes["GET /flaky"]
+	if flaky.Requests != 4 || flaky.Errors != 2 || flaky.Objective != 0.5 || flaky.BurnRate != 1 {
+		t.Errorf("Unexpected budget of the flaky route %+v", flaky)
+	}
+	if flaky.Status != handler.BudgetDegraded {
+		t.Error("Expected flaky route to be degraded")
+	}
+	if ok := routes["GET /ok"]; ok.Errors != 0 || ok.Status != handler.BudgetHealthy {
+		t.Errorf("Unexpected budget of the ok route %+v", ok)
+	}
+
+	resp := e.GET("/health").Expect().Status(iris.StatusServiceUnavailable)
+	resp.JSON().Object().ValueEqual("status", "degraded")
+
+	for _, route := range errorsHandler.Budget().Routes {
+		if route.Route == "GET /health" {
+			t.Error("Expected budget endpoint not to be tracked")
+		}
+	}
+}
+
+func TestItIgnoresBudgetEndpointOverHTTP(t *testing.T) {
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		Budget: handler.BudgetConfig{Objective: 0.99},
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", errorsHandler.BudgetHTTP)
+	server := errorsHandler.Wrap(mux)
+
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+	if routes := errorsHandler.Budget().Routes; len(routes) != 0 {
+		t.Error("Expected budget endpoint not to be tracked, got", routes)
+	}
+}
+
+func TestItIgnoresInvalidObjectives(t *testing.T) {
+	output := &bytes.Buffer{}
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		Logger: log.New(output, "", 0),
+		Budget: handler.BudgetConfig{
+			Objective:   1,
+			MinRequests: 1,
+			Routes: map[string]float64{
+				"GET /flaky": 1.5,
+				"GET /ok":    0.9,
+			},
+		},
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /flaky", func(w http.ResponseWriter, r *http.Request) {
+		panic("Error")
+	})
+	mux.HandleFunc("GET /ok", func(w http.ResponseWriter, r *http.Request) {})
+	server := errorsHandler.Wrap(mux)
+
+	for _, path := range []string{"/flaky", "/ok"} {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	status := errorsHandler.Budget()
+	if len(status.Routes) != 1 || status.Routes[0].Route != "GET /ok" {
+		t.Fatal("Expected only route with valid objective, got", status.Routes)
+	}
+	if _, err := json.Marshal(status); err != nil {
+		t.Error("Expected budget to be encoded, got", err)
+	}
+	if !strings.Contains(output.String(), "budget objective 1.5 of GET /flaky is ignored") {
+		t.Error("Expected invalid objective to be logged, got", output.String())
+	}
+}
+
+func TestItKeepsStreamingOfWrappedWriter(t *testing.T) {
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		Budget:  handler.BudgetConfig{Objective: 0.99},
+		Lockout: handler.LockoutConfig{Threshold: 10},
+	})
+
+	server := httptest.NewServer(errorsHandler.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Error("Expected writer to be http.Flusher")
+			return
+		}
+		if _, ok := w.(http.Hijacker); !ok {
+			t.Error("Expected writer to be http.Hijacker")
+		}
+
+		w.Write([]byte("data: ok\n\n"))
+		flusher.Flush()
+	})))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Error("Expected 200, got", resp.StatusCode)
+	}
+}
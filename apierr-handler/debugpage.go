@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/mlanin/go-apierr"
+	"github.com/mlanin/iris-middlewares/internal/adapter"
+)
+
+// Lines of source shown around the frame line.
+const snippetRadius = 5
+
+// Frame of the stack for the debug page.
+type debugFrame struct {
+	Function string
+	File     string
+	Line     int
+	App      bool
+	Source   []debugLine
+}
+
+// Line of the source snippet.
+type debugLine struct {
+	Number  int
+	Code    string
+	Current bool
+}
+
+// Table of values for the debug page.
+type debugTable struct {
+	Title  string
+	Values map[string]string
+}
+
+// Data for the debug page.
+type debugPage struct {
+	Error  *apierr.APIError
+	Panic  string
+	Method string
+	URL    string
+	Frames []debugFrame
+	Tables []debugTable
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Error.Body.ID}}: {{.Panic}}</title>
+<style>
+body { font-family: sans-serif; margin: 0; background: #f5f5f5; color: #222; }
+header { background: #c0392b; color: #fff; padding: 20px 30px; }
+header h1 { margin: 0 0 10px; font-size: 22px; }
+section { padding: 10px 30px; }
+.frame { background: #fff; border-left: 4px solid #ccc; margin: 10px 0; padding: 8px 12px; }
+.frame.app { border-left-color: #c0392b; }
+.frame .function { font-weight: bold; }
+.frame .file { color: #777; font-size: 13px; }
+pre { margin: 8px 0 0; font-size: 13px; overflow-x: auto; }
+pre .current { background: #fde2e0; display: block; }
+table { border-collapse: collapse; background: #fff; width: 100%; font-size: 13px; }
+td { border-bottom: 1px solid #eee; padding: 4px 8px; vertical-align: top; word-break: break-all; }
+td:first-child { font-weight: bold; width: 25%; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Error.HTTPCode}} {{.Error.Body.ID}}</h1>
+<div>{{.Panic}}</div>
+<div>{{.Method}} {{.URL}}</div>
+</header>
+<section>
+<h2>Stack</h2>
+{{range .Frames}}<div class="frame{{if .App}} app{{end}}">
+<div class="function">{{.Function}}</div>
+<div class="file">{{.File}}:{{.Line}}</div>
+{{if .Source}}<pre>{{range .Source}}<span{{if .Current}} class="current"{{end}}>{{printf "%4d" .Number}}  {{.Code}}</span>
+{{end}}</pre>{{end}}
+</div>
+{{end}}
+</section>
+{{range .Tables}}<section>
+<h2>{{.Title}}</h2>
+{{if .Values}}<table>{{range $key, $value := .Values}}<tr><td>{{$key}}</td><td>{{$value}}</td></tr>{{end}}</table>{{else}}<p>Empty</p>{{end}}
+</section>
+{{end}}
+</body>
+</html>`))
+
+// Check if browser should get the debug page. APIErrors sent on purpose are rendered as usual.
+func (h *Handler) wantsDebugPage(err interface{}, c adapter.Context) bool {
+	switch err.(type) {
+	case *apierr.APIError, *InjectedError:
+		return false
+	}
+
+	return !h.isProduction() && h.isDebugOn() && strings.Contains(c.Request().Header.Get("Accept"), "text/html")
+}
+
+// Render debug page for the error.
+func (h *Handler) renderDebugPage(err interface{}, fail *apierr.APIError, c adapter.Context) string {
+	r := c.Request()
+
+	sessionValues := make(map[string]string)
+	if session := c.Session(); session != nil {
+		for key, value := range session.GetAll() {
+			sessionValues[key] = fmt.Sprintf("%+v", value)
+		}
+	}
+
+	page := &debugPage{
+		Error:  fail,
+		Panic:  fmt.Sprintf("%+v", err),
+		Method: r.Method,
+		URL:    r.URL.String(),
+		Frames: h.debugFrames(),
+		Tables: []debugTable{
+			{Title: "Headers", Values: flatten(redactHeaders(r.Header, h.Config.Dumps.RedactHeaders))},
+			{Title: "Route params", Values: c.Params()},
+			{Title: "Query", Values: flatten(r.URL.Query())},
+			{Title: "Session", Values: sessionValues},
+			{Title: "Environment", Values: environment()},
+		},
+	}
+
+	var buf bytes.Buffer
+	if renderErr := debugTemplate.Execute(&buf, page); renderErr != nil {
+		return template.HTMLEscapeString(fmt.Sprintf("%+v\n%v", err, renderErr))
+	}
+
+	return buf.String()
+}
+
+// Collect frames of the panicking code.
+func (h *Handler) debugFrames() []debugFrame {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(1, pcs)])
+
+	all := make([]debugFrame, 0)
+
+	for {
+		frame, more := frames.Next()
+
+		// Show only frames after the panic.
+		if frame.Function == "runtime.gopanic" {
+			all = all[:0]
+		} else {
+			all = append(all, debugFrame{
+				Function: frame.Function,
+				File:     frame.File,
+				Line:     frame.Line,
+				App:      isAppFile(frame.File),
+			})
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	for i := range all {
+		all[i].Source = snippet(all[i].File, all[i].Line)
+	}
+
+	return all
+}
+
+// Check if file belongs to the application, not to the Go, dependencies or this middleware.
+func isAppFile(file string) bool {
+	return !strings.HasPrefix(file, runtime.GOROOT()) &&
+		!strings.Contains(file, "/pkg/mod/") &&
+		!strings.Contains(file, "iris-middlewares/apierr-handler/")
+}
+
+// Read source lines around the line.
+func snippet(path string, line int) []debugLine {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	lines := make([]debugLine, 0, snippetRadius*2+1)
+	scanner := bufio.NewScanner(file)
+
+	for number := 1; scanner.Scan() && number <= line+snippetRadius; number++ {
+		if number >= line-snippetRadius {
+			lines = append(lines, debugLine{
+				Number:  number,
+				Code:    scanner.Text(),
+				Current: number == line,
+			})
+		}
+	}
+
+	return lines
+}
+
+// Flatten multi-value map.
+func flatten(values map[string][]string) map[string]string {
+	flat := make(map[string]string, len(values))
+	for key, value := range values {
+		flat[key] = strings.Join(value, ", ")
+	}
+
+	return flat
+}
+
+// Environment variables.
+func environment() map[string]string {
+	variables := os.Environ()
+	env := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		if i := strings.Index(variable, "="); i > 0 {
+			env[variable[:i]] = variable[i+1:]
+		}
+	}
+
+	return env
+}
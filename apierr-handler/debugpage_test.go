@@ -0,0 +1,99 @@
+package handler_test
+
+import (
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+)
+
+func TestItRendersDebugPageForBrowsers(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := handler.New(handler.Config{
+		EnvGetter: func() string {
+			return "local"
+		},
+		DebugGetter: func() bool {
+			return true
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/news/{id}", func(ctx iris.Context) {
+		panic("foo")
+	})
+
+	e := httptest.New(t, api)
+
+	body := e.GET("/news/42").WithHeader("Accept", "text/html").WithHeader("Authorization", "Bearer secret-token").WithQuery("page", 2).
+		Expect().
+		Status(iris.StatusInternalServerError).
+		ContentType("text/html").
+		Body()
+	body.Contains("internal_server_error")
+	body.Contains("foo")
+	body.Contains("debugpage_test.go")
+	body.Contains("Route params")
+	body.Contains("<td>Authorization</td><td>[redacted]</td>")
+
+	e.GET("/news/42").WithHeader("Accept", "application/json").
+		Expect().
+		Status(iris.StatusInternalServerError).
+		JSON().
+		Object().Value("error").
+		Object().ValueEqual("message", "foo")
+}
+
+func TestItHidesDebugPageInProduction(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := handler.New(handler.Config{
+		EnvGetter: func() string {
+			return "production"
+		},
+		DebugGetter: func() bool {
+			return true
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		panic("foo")
+	})
+
+	e := httptest.New(t, api)
+	e.GET("/").WithHeader("Accept", "text/html").
+		Expect().
+		Status(iris.StatusInternalServerError).
+		ContentType("application/json")
+}
+
+func TestItRendersAPIErrorsAsJSONForBrowsers(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := handler.New(handler.Config{
+		EnvGetter: func() string {
+			return "local"
+		},
+		DebugGetter: func() bool {
+			return true
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		panic(apierr.NotFound)
+	})
+
+	e := httptest.New(t, api)
+	e.GET("/").WithHeader("Accept", "text/html").
+		Expect().
+		Status(iris.StatusNotFound).
+		ContentType("application/json")
+}
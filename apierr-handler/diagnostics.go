@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/mlanin/go-apierr"
+)
+
+const defaultDiagnosticsInterval = time.Minute
+
+// DiagnosticsConfig for the runtime diagnostics attached to reports of unexpected panics.
+// Goroutine count and uptime are always attached.
+type DiagnosticsConfig struct {
+	// Attach stacks of all goroutines.
+	Goroutines bool
+	// Attach memory stats summary.
+	MemStats bool
+	// Attach GC pause stats.
+	GC bool
+	// Attach module versions and VCS revision.
+	BuildInfo bool
+	// Min interval between two diagnostics. Defaults to 1 minute.
+	Interval time.Duration
+}
+
+// Collects diagnostics not more often than allowed.
+type diagnostics struct {
+	throttle
+	started time.Time
+}
+
+// Lets expensive work run once per interval.
+type throttle struct {
+	last int64
+}
+
+// Make diagnostics of the runtime if they are configured for the environment.
+func (h *Handler) diagnose(err interface{}, fail *apierr.APIError) string {
+	if _, ok := err.(*apierr.APIError); ok || fail.HTTPCode < 500 {
+		return ""
+	}
+
+	config, ok := h.Config.Diagnostics[h.Config.EnvGetter()]
+	if !ok {
+		return ""
+	}
+
+	interval := config.Interval
+	if interval <= 0 {
+		interval = defaultDiagnosticsInterval
+	}
+	if !h.diagnostics.allow(time.Now(), interval) {
+		return ""
+	}
+
+	return h.diagnostics.collect(config)
+}
+
+// Check if the work was not done during the interval.
+func (t *throttle) allow(now time.Time, interval time.Duration) bool {
+	last := atomic.LoadInt64(&t.last)
+	if last != 0 && now.Sub(time.Unix(0, last)) < interval {
+		return false
+	}
+
+	return atomic.CompareAndSwapInt64(&t.last, last, now.UnixNano())
+}
+
+// Collect configured diagnostics.
+func (d *diagnostics) collect(config DiagnosticsConfig) string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "Uptime: %s\nGoroutines: %d\n", time.Since(d.started).Round(time.Second), runtime.NumGoroutine())
+
+	if config.MemStats || config.GC {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+
+		if config.MemStats {
+			fmt.Fprintf(&buf, "Alloc: %d\nTotalAlloc: %d\nSys: %d\nHeapAlloc: %d\nHeapInuse: %d\nHeapObjects: %d\n",
+				mem.Alloc, mem.TotalAlloc, mem.Sys, mem.HeapAlloc, mem.HeapInuse, mem.HeapObjects)
+		}
+		if config.GC {
+			lastPause := mem.PauseNs[(mem.NumGC+255)%256]
+			fmt.Fprintf(&buf, "NumGC: %d\nPauseTotal: %s\nLastPause: %s\nGCCPUFraction: %f\n",
+				mem.NumGC, time.Duration(mem.PauseTotalNs), time.Duration(lastPause), mem.GCCPUFraction)
+		}
+	}
+
+	if config.BuildInfo {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			fmt.Fprintf(&buf, "Go: %s\nMain: %s %s\n", info.GoVersion, info.Main.Path, info.Main.Version)
+			for _, setting := range info.Settings {
+				if setting.Key == "vcs.revision" || setting.Key == "vcs.time" || setting.Key == "vcs.modified" {
+					fmt.Fprintf(&buf, "%s: %s\n", setting.Key, setting.Value)
+				}
+			}
+			for _, dep := range info.Deps {
+				fmt.Fprintf(&buf, "Dep: %s %s\n", dep.Path, dep.Version)
+			}
+		}
+	}
+
+	if config.Goroutines {
+		buf.WriteString("\n")
+		buf.Write(allStacks())
+	}
+
+	return buf.String()
+}
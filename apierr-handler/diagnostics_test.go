@@ -0,0 +1,63 @@
+package handler_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+)
+
+func TestItAttachesRateLimitedDiagnostics(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		Diagnostics: map[string]handler.DiagnosticsConfig{
+			"production": {
+				MemStats: true,
+				GC:       true,
+				Interval: time.Hour,
+			},
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		panic("Error")
+	})
+	api.Get("/api", func(ctx iris.Context) {
+		panic(apierr.InternalServerError)
+	})
+
+	e := httptest.New(t, api)
+	e.GET("/api").Expect().Status(iris.StatusInternalServerError)
+	e.GET("/").Expect().Status(iris.StatusInternalServerError)
+	e.GET("/").Expect().Status(iris.StatusInternalServerError)
+
+	reports := errorsHandler.Reports()
+	if len(reports) != 3 {
+		t.Fatal("Expected 3 reports, got", len(reports))
+	}
+	if reports[0].Diagnostics != "" {
+		t.Error("Expected no diagnostics for APIError")
+	}
+
+	diagnostics := reports[1].Diagnostics
+	for _, line := range []string{"Uptime:", "Goroutines:", "HeapAlloc:", "NumGC:"} {
+		if !strings.Contains(diagnostics, line) {
+			t.Errorf("Expected %q in diagnostics:\n%s", line, diagnostics)
+		}
+	}
+	if !strings.Contains(reports[1].Message, "--> diagnostics:") {
+		t.Error("Expected diagnostics in report message")
+	}
+
+	if reports[2].Diagnostics != "" {
+		t.Error("Expected diagnostics to be rate limited")
+	}
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDumpMaxFiles = 10
+	defaultDumpMaxSize  = 100 << 20
+	defaultDumpInterval = time.Minute
+	dumpPrefix          = "crash-"
+	dumpTimeFormat      = "20060102-150405.000000000"
+	redactedValue       = "[redacted]"
+)
+
+// Headers with credentials that are never written to dumps.
+var credentialHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+	"X-Csrf-Token",
+}
+
+// DumpConfig for the crash dump files.
+type DumpConfig struct {
+	// Directory for the dump files. Dumps are off if empty.
+	Dir string
+	// Max number of kept dump files. Defaults to 10.
+	MaxFiles int
+	// Max total size of kept dump files in bytes. Defaults to 100 MB.
+	MaxSize int64
+	// Additional headers to redact. Authorization, cookies and API keys are always redacted.
+	RedactHeaders []string
+	// Min interval between two dumps, so panic storms don't stop the world on every request.
+	// Defaults to 1 minute, negative turns the limit off.
+	Interval time.Duration
+}
+
+// Writes crash dumps and rotates them.
+type dumper struct {
+	config   DumpConfig
+	throttle throttle
+
+	mu  sync.Mutex
+	seq uint64
+}
+
+// Make dumper if dumps are configured.
+func newDumper(config DumpConfig) *dumper {
+	if config.Dir == "" {
+		return nil
+	}
+	if config.MaxFiles <= 0 {
+		config.MaxFiles = defaultDumpMaxFiles
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = defaultDumpMaxSize
+	}
+	if config.Interval == 0 {
+		config.Interval = defaultDumpInterval
+	}
+
+	return &dumper{config: config}
+}
+
+// Write dump file and return its path. Returns empty path if the dump was written recently.
+func (d *dumper) write(err interface{}, r *http.Request) (string, error) {
+	if d.config.Interval > 0 && !d.throttle.allow(time.Now(), d.config.Interval) {
+		return "", nil
+	}
+
+	content := d.collect(err, r)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := os.MkdirAll(d.config.Dir, 0700); err != nil {
+		return "", err
+	}
+
+	d.seq++
+	name := fmt.Sprintf("%s%s-%d.txt", dumpPrefix, time.Now().UTC().Format(dumpTimeFormat), d.seq)
+	path := filepath.Join(d.config.Dir, name)
+
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		return "", err
+	}
+
+	d.rotate()
+
+	return path, nil
+}
+
+// Collect everything about the crash.
+func (d *dumper) collect(err interface{}, r *http.Request) []byte {
+	var buf bytes.Buffer
+
+	section := func(title string) {
+		fmt.Fprintf(&buf, "\n=== %s ===\n", title)
+	}
+
+	fmt.Fprintf(&buf, "Time: %s\n", time.Now().Format(time.RFC3339Nano))
+	fmt.Fprintf(&buf, "Panic: %+v\n", err)
+
+	section("Request")
+	if request, dumpErr := httputil.DumpRequest(d.redact(r), false); dumpErr == nil {
+		buf.Write(request)
+	}
+
+	section("Stack")
+	buf.Write(debug.Stack())
+
+	section("Goroutines")
+	buf.Write(allStacks())
+
+	section("Memory")
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	fmt.Fprintf(&buf, "Alloc: %d\nTotalAlloc: %d\nSys: %d\nHeapObjects: %d\nNumGC: %d\nGoroutines: %d\n",
+		mem.Alloc, mem.TotalAlloc, mem.Sys, mem.HeapObjects, mem.NumGC, runtime.NumGoroutine())
+
+	section("Build")
+	if info, ok := debug.ReadBuildInfo(); ok {
+		buf.WriteString(info.String())
+	}
+
+	return buf.Bytes()
+}
+
+// Copy request without credentials.
+func (d *dumper) redact(r *http.Request) *http.Request {
+	clone := r.Clone(r.Context())
+	clone.Header = redactHeaders(r.Header, d.config.RedactHeaders)
+
+	return clone
+}
+
+// Copy headers with credentials and additional headers redacted.
+func redactHeaders(header http.Header, additional []string) http.Header {
+	clone := header.Clone()
+
+	for _, name := range append(append([]string{}, credentialHeaders...), additional...) {
+		if clone.Get(name) != "" {
+			clone.Set(name, redactedValue)
+		}
+	}
+
+	return clone
+}
+
+// Remove oldest dumps over the limits.
+func (d *dumper) rotate() {
+	entries, err := os.ReadDir(d.config.Dir)
+	if err != nil {
+		return
+	}
+
+	type dump struct {
+		path string
+		size int64
+	}
+
+	dumps := make([]dump, 0)
+	var total int64
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), dumpPrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		dumps = append(dumps, dump{path: filepath.Join(d.config.Dir, entry.Name()), size: info.Size()})
+		total += info.Size()
+	}
+
+	// Names start with the time, so the oldest go first.
+	sort.Slice(dumps, func(i, j int) bool {
+		return dumps[i].path < dumps[j].path
+	})
+
+	// Always keep the newest one.
+	for len(dumps) > 1 && (len(dumps) > d.config.MaxFiles || total > d.config.MaxSize) {
+		if os.Remove(dumps[0].path) == nil {
+			total -= dumps[0].size
+		}
+		dumps = dumps[1:]
+	}
+}
+
+// Stacks of all goroutines.
+func allStacks() []byte {
+	buf := make([]byte, 1<<16)
+
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
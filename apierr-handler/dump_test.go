@@ -0,0 +1,110 @@
+package handler_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+)
+
+func TestItWritesAndRotatesCrashDumps(t *testing.T) {
+	api := iris.New()
+
+	dir := t.TempDir()
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		Dumps: handler.DumpConfig{
+			Dir:      dir,
+			MaxFiles: 2,
+			Interval: -1,
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		panic("Error")
+	})
+	api.Get("/api", func(ctx iris.Context) {
+		panic(apierr.InternalServerError)
+	})
+
+	e := httptest.New(t, api)
+	for i := 0; i < 3; i++ {
+		e.GET("/").WithHeader("Authorization", "Bearer secret-token").WithHeader("X-Api-Key", "secret-key").
+			Expect().
+			Status(iris.StatusInternalServerError)
+	}
+	e.GET("/api").Expect().Status(iris.StatusInternalServerError)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Error("Expected 2 dumps, got", len(entries))
+	}
+
+	reports := errorsHandler.Reports()
+	if len(reports) != 4 {
+		t.Fatal("Expected 4 reports, got", len(reports))
+	}
+	info, err := os.Stat(reports[2].DumpFile)
+	if err != nil {
+		t.Fatal("Expected dump file in report", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Error("Expected dump to be readable only by the owner, got", info.Mode().Perm())
+	}
+
+	content, err := os.ReadFile(reports[2].DumpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "secret") {
+		t.Error("Expected credentials to be redacted")
+	}
+	if reports[3].DumpFile != "" {
+		t.Error("Expected no dump for APIError")
+	}
+}
+
+func TestItLimitsCrashDumps(t *testing.T) {
+	api := iris.New()
+
+	dir := t.TempDir()
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		Dumps: handler.DumpConfig{Dir: dir},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		panic("Error")
+	})
+
+	e := httptest.New(t, api)
+	for i := 0; i < 3; i++ {
+		e.GET("/").Expect().Status(iris.StatusInternalServerError)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Error("Expected 1 dump per interval, got", len(entries))
+	}
+
+	reports := errorsHandler.Reports()
+	if len(reports) != 3 {
+		t.Fatal("Expected 3 reports, got", len(reports))
+	}
+	if reports[0].DumpFile == "" || reports[1].DumpFile != "" {
+		t.Error("Expected dump only in the first report")
+	}
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mlanin/go-apierr"
+	"github.com/mlanin/iris-middlewares/internal/adapter"
+)
+
+// Header with the requested API version.
+const versionHeader = "API-Version"
+
+// Version from vendor media type, like application/vnd.app.v2+json.
+var vendorVersionRegexp = regexp.MustCompile(`vnd\.[^;,\s]*\.v([0-9][^+;,\s]*)`)
+
+// Envelope of the errors for the API version.
+type Envelope struct {
+	// Version, like "2". Matched with API-Version header and vendor media type in Accept header.
+	Version string
+	// Path prefix of the version routes, like "/v2/".
+	Prefix string
+	// Makes response body from the error.
+	Format func(fail *apierr.APIError) interface{}
+}
+
+// Make response body in the envelope of the requested API version.
+func (h *Handler) envelope(fail *apierr.APIError, c adapter.Context) interface{} {
+	if envelope := h.findEnvelope(c); envelope != nil {
+		return envelope.Format(fail)
+	}
+
+	return fail
+}
+
+// Find envelope by header, media type or route prefix.
+func (h *Handler) findEnvelope(c adapter.Context) *Envelope {
+	if len(h.Config.Envelopes) == 0 {
+		return nil
+	}
+
+	r := c.Request()
+
+	version := strings.TrimPrefix(strings.TrimSpace(r.Header.Get(versionHeader)), "v")
+	if version == "" {
+		if match := vendorVersionRegexp.FindStringSubmatch(r.Header.Get("Accept")); match != nil {
+			version = match[1]
+		}
+	}
+
+	for i := range h.Config.Envelopes {
+		envelope := &h.Config.Envelopes[i]
+
+		if version != "" {
+			if envelope.Version == version {
+				return envelope
+			}
+		} else if envelope.Prefix != "" && strings.HasPrefix(r.URL.Path, envelope.Prefix) {
+			return envelope
+		}
+	}
+
+	return nil
+}
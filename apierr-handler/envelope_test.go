@@ -0,0 +1,58 @@
+package handler_test
+
+import (
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+)
+
+func TestItSelectsEnvelopeByVersion(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		Envelopes: []handler.Envelope{
+			{
+				Version: "2",
+				Prefix:  "/v2/",
+				Format: func(fail *apierr.APIError) interface{} {
+					return map[string]interface{}{
+						"code":   fail.Body.ID,
+						"detail": fail.Body.Message,
+					}
+				},
+			},
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/v1/news", func(ctx iris.Context) {
+		panic(apierr.NotFound)
+	})
+	api.Get("/v2/news", func(ctx iris.Context) {
+		panic(apierr.NotFound)
+	})
+
+	e := httptest.New(t, api)
+
+	apierrtest.AssertAPIError(t, e.GET("/v1/news").Expect(), "not_found", iris.StatusNotFound)
+
+	e.GET("/v2/news").
+		Expect().
+		Status(iris.StatusNotFound).
+		JSON().Object().ValueEqual("code", "not_found")
+
+	e.GET("/v1/news").WithHeader("API-Version", "2").
+		Expect().
+		JSON().Object().ValueEqual("code", "not_found")
+
+	e.GET("/v1/news").WithHeader("Accept", "application/vnd.news.v2+json").
+		Expect().
+		JSON().Object().ValueEqual("code", "not_found")
+
+	apierrtest.AssertAPIError(t, e.GET("/v2/news").WithHeader("API-Version", "1").Expect(), "not_found", iris.StatusNotFound)
+}
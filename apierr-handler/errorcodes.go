@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/kataras/iris/v12"
+	"github.com/mlanin/go-apierr"
+)
+
+// MethodNotAllowed is sent when route exists, but not for the request method.
+var MethodNotAllowed = &apierr.APIError{
+	Body: apierr.Body{
+		ID:      "method_not_allowed",
+		Message: "Method not allowed.",
+	},
+	HTTPCode: http.StatusMethodNotAllowed,
+}
+
+// RegisterErrorCodes makes router level errors go through the handler,
+// so unknown routes and methods get APIErrors instead of plain text.
+func (h *Handler) RegisterErrorCodes(app *iris.Application) {
+	// Iris sets Allow header for these responses.
+	app.Configure(iris.WithFireMethodNotAllowed)
+
+	app.OnErrorCode(iris.StatusNotFound, func(ctx iris.Context) {
+		h.Handle(ctx, apierr.NotFound)
+	})
+	app.OnErrorCode(iris.StatusMethodNotAllowed, func(ctx iris.Context) {
+		h.Handle(ctx, MethodNotAllowed)
+	})
+	app.OnErrorCode(iris.StatusInternalServerError, func(ctx iris.Context) {
+		h.Handle(ctx, apierr.InternalServerError)
+	})
+}
@@ -0,0 +1,31 @@
+package handler_test
+
+import (
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+)
+
+func TestItHandlesRouterErrors(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+	errorsHandler.RegisterErrorCodes(api)
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/news", func(ctx iris.Context) {
+		ctx.WriteString("News")
+	})
+
+	e := httptest.New(t, api)
+
+	apierrtest.AssertAPIError(t, e.GET("/missing").Expect(), "not_found", iris.StatusNotFound)
+
+	resp := e.POST("/news").Expect()
+	apierrtest.AssertAPIError(t, resp, "method_not_allowed", iris.StatusMethodNotAllowed)
+	resp.Header("Allow").Contains("GET")
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/kataras/iris/v12"
+	"github.com/mlanin/go-apierr"
+	"github.com/mlanin/iris-middlewares/internal/adapter"
+)
+
+// Key to store the handler in the Iris context.
+const handlerKey = "apierr-handler"
+
+// PanicError is returned by Group.Wait when function panicked.
+type PanicError struct {
+	// Converted error.
+	Fail *apierr.APIError
+	// Original recovered value.
+	Value interface{}
+
+	// Set when the panic is already reported, so the handler doesn't report it again.
+	reported   bool
+	incidentID string
+}
+
+// Error message.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in goroutine: %+v", e.Value)
+}
+
+// Request that started background work.
+type origin struct {
+	handler *Handler
+	// Details of the request that can be used after it is done.
+	request adapter.Context
+}
+
+// Go runs fn in a goroutine, recovers its panic and reports it with the request details.
+// Handler must be registered for the request, otherwise panic is only logged.
+func Go(ctx iris.Context, fn func()) {
+	o := originOf(ctx)
+
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				o.report(err)
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// Group runs functions in goroutines and waits for them, like errgroup does.
+type Group struct {
+	origin *origin
+	cancel context.CancelFunc
+
+	wg   sync.WaitGroup
+	once sync.Once
+	err  error
+}
+
+// NewGroup makes group for the request. Returned context is cancelled
+// when the request is done or the first function fails.
+func NewGroup(ctx iris.Context) (*Group, context.Context) {
+	groupCtx, cancel := context.WithCancel(ctx.Request().Context())
+
+	return &Group{origin: originOf(ctx), cancel: cancel}, groupCtx
+}
+
+// Go runs fn in a goroutine. Panic is reported and returned from Wait as *PanicError.
+func (g *Group) Go(fn func() error) {
+	g.wg.Add(1)
+
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				g.fail(g.origin.report(err))
+			}
+		}()
+
+		if err := fn(); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+// Wait for all functions and return the first error.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+
+	return g.err
+}
+
+// Remember the first error and cancel the rest.
+func (g *Group) fail(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
+
+// Collect request details while the context is still valid.
+func originOf(ctx iris.Context) *origin {
+	h, _ := ctx.Values().Get(handlerKey).(*Handler)
+
+	logger := ctx.Application().Logger()
+	log := func(message string) {
+		logger.Error(message)
+	}
+	if h != nil {
+		log = func(message string) {
+			h.Config.Logger.Println(message)
+		}
+	}
+
+	return &origin{
+		handler: h,
+		request: adapter.Detach(adapter.FromIris(ctx), log),
+	}
+}
+
+// Convert and report background panic the same way as the request one.
+func (o *origin) report(err interface{}) *PanicError {
+	if o.handler == nil {
+		r := o.request.Request()
+		o.request.Log(fmt.Sprintf("[apierr-handler] panic in goroutine of %s %s: %+v", r.Method, r.URL, err))
+
+		return &PanicError{Fail: apierr.InternalServerError, Value: err, reported: true}
+	}
+
+	fail, incidentID := o.handler.process(err, o.request)
+
+	// Don't report it again if it's panicked in the request.
+	clone := *fail
+	clone.ShouldReport = false
+
+	return &PanicError{Fail: &clone, Value: err, reported: true, incidentID: incidentID}
+}
+
+// Get background panic that is already reported.
+func reportedPanic(err interface{}) *PanicError {
+	e, ok := err.(error)
+	if !ok {
+		return nil
+	}
+
+	var panicErr *PanicError
+	if errors.As(e, &panicErr) && panicErr.reported {
+		return panicErr
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package handler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+)
+
+func TestItRecoversPanicInGoroutine(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/news", func(ctx iris.Context) {
+		handler.Go(ctx, func() {
+			panic("Error")
+		})
+
+		ctx.WriteString("Done")
+	})
+
+	e := httptest.New(t, api)
+	e.GET("/news").WithHeader("X-Request-Id", "abc").
+		Expect().
+		Status(iris.StatusOK)
+
+	var reports []*handler.Report
+	for i := 0; i < 100 && len(reports) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		reports = errorsHandler.Reports()
+	}
+
+	if len(reports) != 1 {
+		t.Fatal("Expected 1 report, got", len(reports))
+	}
+	if reports[0].Route != "/news" || reports[0].RequestID != "abc" {
+		t.Error("Unexpected report", reports[0])
+	}
+}
+
+func TestItReturnsPanicFromGroup(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/news", func(ctx iris.Context) {
+		group, _ := handler.NewGroup(ctx)
+		group.Go(func() error {
+			return nil
+		})
+		group.Go(func() error {
+			panic("Error")
+		})
+
+		if err := group.Wait(); err != nil {
+			panic(err)
+		}
+
+		ctx.WriteString("Done")
+	})
+
+	e := httptest.New(t, api)
+	apierrtest.AssertAPIError(t, e.GET("/news").Expect(), "internal_server_error", iris.StatusInternalServerError)
+
+	if reports := errorsHandler.Reports(); len(reports) != 1 {
+		t.Error("Expected 1 report, got", len(reports))
+	}
+}
+
+func TestItReportsGroupPanicOnceOutsideProduction(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		EnvGetter: func() string {
+			return "develop"
+		},
+	})
+
+	reported := 0
+	errorsHandler.AfterReport(func(fail *apierr.APIError, message string) {
+		reported++
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/news", func(ctx iris.Context) {
+		group, _ := handler.NewGroup(ctx)
+		group.Go(func() error {
+			panic("Error")
+		})
+
+		if err := group.Wait(); err != nil {
+			panic(err)
+		}
+	})
+
+	e := httptest.New(t, api)
+	resp := e.GET("/news").Expect().Status(iris.StatusInternalServerError)
+
+	reports := errorsHandler.Reports()
+	if len(reports) != 1 {
+		t.Fatal("Expected 1 report, got", len(reports))
+	}
+	if reported != 1 {
+		t.Error("Expected report hooks to run once, got", reported)
+	}
+
+	resp.JSON().Object().Value("meta").Object().ValueEqual("incident_id", reports[0].IncidentID)
+}
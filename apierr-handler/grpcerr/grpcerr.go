@@ -0,0 +1,281 @@
+// Package grpcerr maps APIErrors to gRPC statuses and back.
+package grpcerr
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mlanin/go-apierr"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Domain of the ErrorInfo details.
+	errorDomain = "apierr"
+	// ErrorInfo metadata key with the original HTTP status.
+	httpStatusKey = "http_status"
+	// ID of the validation errors.
+	validationFailedID = "validation_failed"
+	// Message of the unexpected panics.
+	internalMessage = "Internal server error."
+)
+
+// gRPC codes by HTTP statuses.
+var codesByStatus = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusPreconditionFailed:  codes.FailedPrecondition,
+	http.StatusUnprocessableEntity: codes.InvalidArgument,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	499:                            codes.Canceled,
+	http.StatusInternalServerError: codes.Internal,
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusBadGateway:          codes.Unavailable,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+}
+
+// HTTP statuses by gRPC codes.
+var statusesByCode = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+}
+
+// Code returns gRPC code for the HTTP status.
+func Code(httpStatus int) codes.Code {
+	if code, ok := codesByStatus[httpStatus]; ok {
+		return code
+	}
+
+	switch {
+	case httpStatus >= 500:
+		return codes.Internal
+	case httpStatus >= 400:
+		return codes.FailedPrecondition
+	}
+
+	return codes.Unknown
+}
+
+// HTTPStatus returns HTTP status for the gRPC code.
+func HTTPStatus(code codes.Code) int {
+	if httpStatus, ok := statusesByCode[code]; ok {
+		return httpStatus
+	}
+
+	return http.StatusInternalServerError
+}
+
+// ToStatus converts APIError to gRPC status. Id and HTTP status are kept in ErrorInfo,
+// validation errors become BadRequest field violations.
+func ToStatus(fail *apierr.APIError) *status.Status {
+	st := status.New(Code(fail.HTTPCode), fail.Body.Message)
+
+	info := &errdetails.ErrorInfo{
+		Reason: fail.Body.ID,
+		Domain: errorDomain,
+		Metadata: map[string]string{
+			httpStatusKey: strconv.Itoa(fail.HTTPCode),
+		},
+	}
+
+	var withDetails *status.Status
+	var err error
+
+	if violations := fieldViolations(fail); len(violations) > 0 {
+		withDetails, err = st.WithDetails(info, &errdetails.BadRequest{FieldViolations: violations})
+	} else {
+		withDetails, err = st.WithDetails(info)
+	}
+
+	if err == nil {
+		return withDetails
+	}
+
+	return st
+}
+
+// Error converts APIError to gRPC error to return it from the service.
+func Error(fail *apierr.APIError) error {
+	return ToStatus(fail).Err()
+}
+
+// FromStatus converts gRPC status to APIError.
+// Messages of the 5xx statuses made not by ToStatus can leak internals of the service,
+// so they are replaced with the status text and kept in the error context.
+func FromStatus(st *status.Status) *apierr.APIError {
+	fail := &apierr.APIError{
+		Body: apierr.Body{
+			ID:      snakeCase(st.Code().String()),
+			Message: st.Message(),
+		},
+		HTTPCode: HTTPStatus(st.Code()),
+	}
+
+	public := false
+	errors := []apierr.ValidationError{}
+
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if detail.GetDomain() != errorDomain {
+				continue
+			}
+			public = true
+			fail.Body.ID = detail.GetReason()
+			if httpStatus, err := strconv.Atoi(detail.GetMetadata()[httpStatusKey]); err == nil {
+				fail.HTTPCode = httpStatus
+			}
+		case *errdetails.BadRequest:
+			for _, violation := range detail.GetFieldViolations() {
+				errors = append(errors, apierr.ValidationError{
+					Field:   violation.GetField(),
+					Message: violation.GetDescription(),
+				})
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		if fail.Body.ID == snakeCase(st.Code().String()) {
+			fail.Body.ID = validationFailedID
+		}
+		fail.AddMeta(&apierr.ValidationErrors{
+			Errors: errors,
+		})
+		fail.AddContext(errors)
+	}
+
+	fail.ShouldReport = fail.HTTPCode >= 500
+
+	if fail.ShouldReport && !public {
+		fail.Body.Message = http.StatusText(fail.HTTPCode) + "."
+		fail.AddContext(&statusContext{
+			Code:    st.Code().String(),
+			Message: st.Message(),
+		})
+	}
+
+	return fail
+}
+
+// Original status of the masked error.
+type statusContext struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Convert is handler.Converter for gRPC errors.
+func Convert(err error) *apierr.APIError {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+
+	return FromStatus(st)
+}
+
+// UnaryServerInterceptor recovers APIErrors panicked by the service and returns them as gRPC statuses.
+// Other panics are written to the logger with the stack and clients get generic Internal error.
+// Logger defaults to stderr.
+func UnaryServerInterceptor(logger *log.Logger) grpc.UnaryServerInterceptor {
+	if logger == nil {
+		logger = log.New(os.Stderr, "[grpcerr] ", log.LstdFlags)
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = recoveredError(recovered, info.FullMethod, logger)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// Convert recovered value to gRPC error.
+func recoveredError(recovered interface{}, method string, logger *log.Logger) error {
+	switch recovered := recovered.(type) {
+	case *apierr.APIError:
+		return Error(recovered)
+	case error:
+		if st, ok := status.FromError(recovered); ok {
+			return st.Err()
+		}
+	}
+
+	logger.Printf("panic in %s: %+v\n%s", method, recovered, debug.Stack())
+
+	return status.Error(codes.Internal, internalMessage)
+}
+
+// Read validation errors from the error meta.
+func fieldViolations(fail *apierr.APIError) []*errdetails.BadRequest_FieldViolation {
+	data, err := json.Marshal(fail)
+	if err != nil {
+		return nil
+	}
+
+	var body struct {
+		Meta apierr.ValidationErrors `json:"meta"`
+	}
+	if json.Unmarshal(data, &body) != nil {
+		return nil
+	}
+
+	violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(body.Meta.Errors))
+	for _, e := range body.Meta.Errors {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       e.Field,
+			Description: e.Message,
+		})
+	}
+
+	return violations
+}
+
+// Convert CamelCase code name to snake_case id.
+func snakeCase(name string) string {
+	var b strings.Builder
+
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,139 @@
+package grpcerr_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+	"github.com/mlanin/iris-middlewares/apierr-handler/grpcerr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Health service failing with validation error.
+type failingHealth struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (s *failingHealth) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	fail := *apierr.ValiationFailed
+	fail.AddMeta(&apierr.ValidationErrors{
+		Errors: []apierr.ValidationError{
+			{Field: "service", Message: "Cannot be blank"},
+		},
+	})
+
+	panic(&fail)
+}
+
+// Health service panicking with unexpected value.
+type brokenHealth struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (s *brokenHealth) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	panic("dial tcp 10.0.0.1:5432: connection refused")
+}
+
+func dial(t *testing.T) grpc_health_v1.HealthClient {
+	return dialServer(t, &failingHealth{}, nil)
+}
+
+func dialServer(t *testing.T, service grpc_health_v1.HealthServer, logger *log.Logger) grpc_health_v1.HealthClient {
+	listener := bufconn.Listen(1 << 20)
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(grpcerr.UnaryServerInterceptor(logger)))
+	grpc_health_v1.RegisterHealthServer(server, service)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	return grpc_health_v1.NewHealthClient(conn)
+}
+
+func TestItKeepsAPIErrorOverGRPC(t *testing.T) {
+	_, err := dial(t).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+
+	fail := grpcerr.Convert(err)
+	if fail == nil {
+		t.Fatal("Expected gRPC status error, got", err)
+	}
+	if fail.Body.ID != "validation_failed" || fail.HTTPCode != 422 {
+		t.Error("Unexpected error", fail.Body.ID, fail.HTTPCode)
+	}
+}
+
+func TestItRendersGRPCErrorInHandler(t *testing.T) {
+	client := dial(t)
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		Converters: []handler.Converter{grpcerr.Convert},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/health", func(ctx iris.Context) {
+		if _, err := client.Check(ctx.Request().Context(), &grpc_health_v1.HealthCheckRequest{}); err != nil {
+			panic(err)
+		}
+	})
+
+	e := httptest.New(t, api)
+	apierrtest.AssertValidationError(t, e.GET("/health").Expect(), "service", "Cannot be blank")
+}
+
+func TestItHidesUnexpectedPanicOverGRPC(t *testing.T) {
+	output := &bytes.Buffer{}
+	client := dialServer(t, &brokenHealth{}, log.New(output, "", 0))
+
+	_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+
+	st, _ := status.FromError(err)
+	if st.Code() != codes.Internal || st.Message() != "Internal server error." {
+		t.Error("Unexpected status", st.Code(), st.Message())
+	}
+	if !strings.Contains(output.String(), "connection refused") || !strings.Contains(output.String(), "goroutine") {
+		t.Error("Expected panic with stack in log, got", output.String())
+	}
+}
+
+func TestItMasksMessageOfUpstreamFailure(t *testing.T) {
+	fail := grpcerr.FromStatus(status.New(codes.Internal, "pq: password authentication failed"))
+
+	if fail.Body.Message != "Internal Server Error." || fail.HTTPCode != 500 {
+		t.Error("Unexpected error", fail.Body.Message, fail.HTTPCode)
+	}
+	if fail.Context == nil {
+		t.Error("Expected original status in context")
+	}
+
+	fail = grpcerr.FromStatus(status.New(codes.NotFound, "News not found."))
+	if fail.Body.Message != "News not found." {
+		t.Error("Expected message of client error to be kept, got", fail.Body.Message)
+	}
+}
@@ -4,15 +4,15 @@ import (
 	"errors"
 	"testing"
 
-	"github.com/kataras/iris"
-	"github.com/kataras/iris/httptest"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
 	"github.com/mlanin/go-apierr"
 	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
 )
 
 func TestItCatchesPanicWithAPIError(t *testing.T) {
 	api := iris.New()
-	defer api.Close()
 
 	errorsHandler := handler.New(handler.Config{
 		EnvGetter: func() string {
@@ -23,24 +23,18 @@ func TestItCatchesPanicWithAPIError(t *testing.T) {
 		},
 	})
 
-	api.Use(errorsHandler)
+	api.Use(errorsHandler.Serve)
 
-	api.Get("/", func(ctx *iris.Context) {
+	api.Get("/", func(ctx iris.Context) {
 		panic(apierr.NotFound)
 	})
 
-	e := httptest.New(api, t, httptest.ExplicitURL(true))
-	e.GET("/").
-		Expect().
-		Status(iris.StatusNotFound).
-		JSON().
-		Object().ContainsKey("error").Value("error").
-		Object().ValueEqual("id", "not_found")
+	e := httptest.New(t, api)
+	apierrtest.AssertAPIError(t, e.GET("/").Expect(), "not_found", iris.StatusNotFound)
 }
 
 func TestItCatchesPanicWithAPIErrorWithMeta(t *testing.T) {
 	api := iris.New()
-	defer api.Close()
 
 	errorsHandler := handler.New(handler.Config{
 		EnvGetter: func() string {
@@ -51,9 +45,9 @@ func TestItCatchesPanicWithAPIErrorWithMeta(t *testing.T) {
 		},
 	})
 
-	api.Use(errorsHandler)
+	api.Use(errorsHandler.Serve)
 
-	api.Get("/", func(ctx *iris.Context) {
+	api.Get("/", func(ctx iris.Context) {
 		fail := *apierr.BadRequest
 		fail.AddMeta(struct {
 			Fail string `json:"fail"`
@@ -85,7 +79,7 @@ func TestItCatchesPanicWithAPIErrorWithMeta(t *testing.T) {
 		"required": ["error", "meta"]
 	}`
 
-	e := httptest.New(api, t, httptest.ExplicitURL(true))
+	e := httptest.New(t, api)
 	e.GET("/").
 		Expect().
 		Status(iris.StatusBadRequest).
@@ -94,7 +88,6 @@ func TestItCatchesPanicWithAPIErrorWithMeta(t *testing.T) {
 
 func TestItCatchesPanicWithNativeError(t *testing.T) {
 	api := iris.New()
-	defer api.Close()
 
 	errorsHandler := handler.New(handler.Config{
 		EnvGetter: func() string {
@@ -105,13 +98,13 @@ func TestItCatchesPanicWithNativeError(t *testing.T) {
 		},
 	})
 
-	api.Use(errorsHandler)
+	api.Use(errorsHandler.Serve)
 
-	api.Get("/", func(ctx *iris.Context) {
+	api.Get("/", func(ctx iris.Context) {
 		panic(errors.New("Error"))
 	})
 
-	e := httptest.New(api, t, httptest.ExplicitURL(true))
+	e := httptest.New(t, api)
 	e.GET("/").
 		Expect().
 		Status(iris.StatusInternalServerError).
@@ -122,7 +115,6 @@ func TestItCatchesPanicWithNativeError(t *testing.T) {
 
 func TestItCatchesPanicWithString(t *testing.T) {
 	api := iris.New()
-	defer api.Close()
 
 	errorsHandler := handler.New(handler.Config{
 		EnvGetter: func() string {
@@ -133,13 +125,13 @@ func TestItCatchesPanicWithString(t *testing.T) {
 		},
 	})
 
-	api.Use(errorsHandler)
+	api.Use(errorsHandler.Serve)
 
-	api.Get("/", func(ctx *iris.Context) {
+	api.Get("/", func(ctx iris.Context) {
 		panic("Error")
 	})
 
-	e := httptest.New(api, t, httptest.ExplicitURL(true))
+	e := httptest.New(t, api)
 	e.GET("/").
 		Expect().
 		Status(iris.StatusInternalServerError).
@@ -150,7 +142,6 @@ func TestItCatchesPanicWithString(t *testing.T) {
 
 func TestItCatchesPanicWithStringForLocal(t *testing.T) {
 	api := iris.New()
-	defer api.Close()
 
 	errorsHandler := handler.New(handler.Config{
 		EnvGetter: func() string {
@@ -161,13 +152,13 @@ func TestItCatchesPanicWithStringForLocal(t *testing.T) {
 		},
 	})
 
-	api.Use(errorsHandler)
+	api.Use(errorsHandler.Serve)
 
-	api.Get("/", func(ctx *iris.Context) {
+	api.Get("/", func(ctx iris.Context) {
 		panic("foo")
 	})
 
-	e := httptest.New(api, t, httptest.ExplicitURL(true))
+	e := httptest.New(t, api)
 	e.GET("/").
 		Expect().
 		Status(iris.StatusInternalServerError).
@@ -178,7 +169,6 @@ func TestItCatchesPanicWithStringForLocal(t *testing.T) {
 
 func TestItCatchesPanicWithAnyValue(t *testing.T) {
 	api := iris.New()
-	defer api.Close()
 
 	errorsHandler := handler.New(handler.Config{
 		EnvGetter: func() string {
@@ -189,13 +179,13 @@ func TestItCatchesPanicWithAnyValue(t *testing.T) {
 		},
 	})
 
-	api.Use(errorsHandler)
+	api.Use(errorsHandler.Serve)
 
-	api.Get("/", func(ctx *iris.Context) {
+	api.Get("/", func(ctx iris.Context) {
 		panic(false)
 	})
 
-	e := httptest.New(api, t, httptest.ExplicitURL(true))
+	e := httptest.New(t, api)
 	e.GET("/").
 		Expect().
 		Status(iris.StatusInternalServerError).
@@ -203,3 +193,74 @@ func TestItCatchesPanicWithAnyValue(t *testing.T) {
 		Object().ContainsKey("error").Value("error").
 		Object().ValueEqual("id", "internal_server_error")
 }
+
+func TestItRunsTransformersAndRenderHooks(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := handler.New(handler.Config{
+		EnvGetter: func() string {
+			return "production"
+		},
+		DebugGetter: func() bool {
+			return false
+		},
+	})
+
+	errorsHandler.Transform(func(fail *apierr.APIError, ctx iris.Context) *apierr.APIError {
+		fail.HTTPCode = iris.StatusGone
+		return fail
+	})
+	errorsHandler.BeforeRender(func(fail *apierr.APIError, ctx iris.Context) {
+		ctx.Header("X-Error-Id", fail.Body.ID)
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		panic(apierr.NotFound)
+	})
+
+	e := httptest.New(t, api)
+	e.GET("/").
+		Expect().
+		Status(iris.StatusGone).
+		Header("X-Error-Id").Equal("not_found")
+
+	if apierr.NotFound.HTTPCode != iris.StatusNotFound {
+		t.Error("Transformer must not change predefined errors")
+	}
+}
+
+func TestItRecoversPanicsInHooks(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := handler.New(handler.Config{
+		EnvGetter: func() string {
+			return "production"
+		},
+		DebugGetter: func() bool {
+			return false
+		},
+	})
+
+	errorsHandler.Transform(func(fail *apierr.APIError, ctx iris.Context) *apierr.APIError {
+		panic("broken transformer")
+	})
+	errorsHandler.BeforeRender(func(fail *apierr.APIError, ctx iris.Context) {
+		panic("broken hook")
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		panic(apierr.NotFound)
+	})
+
+	e := httptest.New(t, api)
+	e.GET("/").
+		Expect().
+		Status(iris.StatusNotFound).
+		JSON().
+		Object().ContainsKey("error").Value("error").
+		Object().ValueEqual("id", "not_found")
+}
@@ -1,27 +1,81 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
-	"regexp"
+	"os"
 	"runtime"
 	"runtime/debug"
 	"strings"
+	"sync/atomic"
+	"time"
 
-	"github.com/kataras/iris"
+	"github.com/kataras/iris/v12"
 	"github.com/mlanin/go-apierr"
+	"github.com/mlanin/iris-middlewares/internal/adapter"
+	"github.com/mlanin/iris-middlewares/internal/clientip"
 )
 
 // Config for the Handler.
 type Config struct {
 	EnvGetter   func() string
 	DebugGetter func() bool
+
+	// Reporters receive reports asynchronously.
+	// If empty, reports are written to the Iris log right away.
+	Reporters []Reporter
+	// Size of the reports buffer. Defaults to 1024.
+	QueueSize int
+	// Number of workers processing the reports. Defaults to 1.
+	Workers int
+	// Which report to drop when the buffer is full.
+	DropPolicy DropPolicy
+	// Circuit breaker settings for every reporter.
+	Breaker BreakerConfig
+	// Crash dumps for unexpected panics in production.
+	Dumps DumpConfig
+	// Runtime diagnostics for reports of unexpected panics by environment, like "production".
+	Diagnostics map[string]DiagnosticsConfig
+	// Error envelopes for API versions. Errors are sent as is for other versions.
+	Envelopes []Envelope
+	// Security audit log for 401 and 403 errors.
+	Audit AuditConfig
+	// Error budget of the routes against SLO targets.
+	Budget BudgetConfig
+	// Lockout of the clients after repeated 401, 403 and 422 errors.
+	Lockout LockoutConfig
+	// Converters turn known errors into APIErrors before they become InternalServerError.
+	Converters []Converter
+	// Status of the response with several joined errors. Defaults to HighestStatus.
+	MultiErrorStatus StatusRule
+	// IPs or CIDRs of the proxies trusted to pass client IP in
+	// Forwarded, X-Forwarded-For and X-Real-IP headers.
+	TrustedProxies []string
+	// Logger for the handler's own failures. Defaults to stderr.
+	Logger *log.Logger
 }
 
+// Converter makes APIError from the error it knows or returns nil.
+type Converter func(err error) *apierr.APIError
+
 // Handler for APIErrors.
 type Handler struct {
 	Config Config
+
+	transformers []Transformer
+	renderHooks  []RenderHook
+	reportHooks  []ReportHook
+
+	queue          *queue
+	dumper         *dumper
+	diagnostics    *diagnostics
+	budget         *budget
+	lockout        *LockoutConfig
+	trustedProxies []*net.IPNet
 }
 
 // New restores the server on internal server errors (panics)
@@ -29,33 +83,222 @@ type Handler struct {
 //
 // is here for compatiblity
 func New(cfg Config) *Handler {
-	return &Handler{Config: cfg}
+	if cfg.Logger == nil {
+		cfg.Logger = log.New(os.Stderr, "[apierr-handler] ", log.LstdFlags)
+	}
+
+	h := &Handler{
+		Config:         cfg,
+		dumper:         newDumper(cfg.Dumps),
+		diagnostics:    &diagnostics{started: time.Now()},
+		budget:         newBudget(cfg.Budget, cfg.Logger),
+		lockout:        newLockout(cfg.Lockout),
+		trustedProxies: clientip.ParseNetworks(cfg.TrustedProxies),
+	}
+
+	if len(cfg.Reporters) > 0 {
+		h.queue = newQueue(cfg, h.wrapReporters(cfg.Reporters))
+	}
+
+	return h
 }
 
 // Serve the middleware.
-func (h *Handler) Serve(ctx *iris.Context) {
-	defer func() {
-		messages := make([]string, 0)
+func (h *Handler) Serve(ctx iris.Context) {
+	// Let background work started by Go find the handler.
+	ctx.Values().Set(handlerKey, h)
+
+	key := h.lockoutKey(ctx, ctx.Request())
+	if retryAfter := h.lockedOut(key); retryAfter != "" {
+		ctx.Header("Retry-After", retryAfter)
+		h.handle(TooManyRequests, adapter.FromIris(ctx))
+		return
+	}
 
+	defer func() {
 		if err := recover(); err != nil {
-			fail := h.convertToAPIError(err)
-
-			if h.needToReport(fail) {
-				messages = append(messages, fmt.Sprintf("[apierr.APIError] %+v [%+v]", err, fail.Context))
-				if h.needToAddTrace(fail) {
-					messages = append(messages, fmt.Sprintf("--> %+v", h.thrower()))
-					messages = append(messages, string(debug.Stack()))
-				}
-				ctx.Log(strings.Join(messages, "\n"))
+			// Let the server abort the response silently, like Wrap does.
+			if err == http.ErrAbortHandler {
+				panic(err)
 			}
 
-			ctx.JSON(fail.HTTPCode, fail)
+			h.handle(err, adapter.FromIris(ctx))
 		}
+
+		h.track(ctx, key)
 	}()
 
 	ctx.Next()
 }
 
+// Handle sends error through the same pipeline as recovered panics.
+// Lets other middlewares respond with APIErrors without panicking.
+func (h *Handler) Handle(ctx iris.Context, err interface{}) {
+	h.handle(err, adapter.FromIris(ctx))
+}
+
+// Convert, report and render recovered error.
+func (h *Handler) handle(err interface{}, c adapter.Context) {
+	fail, incidentID := h.process(err, c)
+
+	h.beforeRender(fail, c)
+
+	if h.wantsDebugPage(err, c) {
+		c.HTML(fail.HTTPCode, h.renderDebugPage(err, fail, c))
+		return
+	}
+
+	body := h.envelope(fail, c)
+	if incidentID != "" {
+		var ok bool
+		if body, ok = withIncident(body, incidentID); !ok {
+			h.Config.Logger.Printf("can't add incident %s to the response body of %s", incidentID, fail.Body.ID)
+		}
+	}
+
+	c.JSON(fail.HTTPCode, body)
+}
+
+// Convert, audit and report error. Returns the error and its incident ID.
+func (h *Handler) process(err interface{}, c adapter.Context) (*apierr.APIError, string) {
+	fail := h.transform(h.convertToAPIError(err), c)
+
+	// Injected errors are not real failures.
+	if isInjected(err) {
+		return fail, ""
+	}
+
+	h.recordSpan(err, fail, c)
+	h.audit(fail, c)
+
+	// Background panic was reported when it happened.
+	if panicErr := reportedPanic(err); panicErr != nil {
+		return fail, panicErr.incidentID
+	}
+
+	if !h.needToReport(fail) {
+		return fail, ""
+	}
+
+	incidentID := ""
+	if h.needIncident(fail) {
+		incidentID = newIncidentID()
+	}
+
+	message := h.makeReport(err, fail)
+	if incidentID != "" {
+		message = "[incident " + incidentID + "] " + message
+	}
+
+	dump := h.dump(err, c)
+	if dump != "" {
+		message += "\n--> dump: " + dump
+	}
+
+	diagnosis := h.diagnose(err, fail)
+	if diagnosis != "" {
+		message += "\n--> diagnostics:\n" + diagnosis
+	}
+
+	h.report(&Report{
+		Error:       fail,
+		Panic:       err,
+		Message:     message,
+		Method:      c.Request().Method,
+		URL:         c.Request().URL.String(),
+		Route:       c.Route(),
+		RequestID:   c.RequestID(),
+		ClientIP:    h.clientIP(c.Request()),
+		DumpFile:    dump,
+		Diagnostics: diagnosis,
+		IncidentID:  incidentID,
+		Time:        time.Now(),
+	}, c)
+	h.afterReport(fail, message, c)
+
+	return fail, incidentID
+}
+
+// Write crash dump for unexpected panic in production.
+func (h *Handler) dump(err interface{}, c adapter.Context) string {
+	if h.dumper == nil || !h.isProduction() {
+		return ""
+	}
+	if _, ok := err.(*apierr.APIError); ok {
+		return ""
+	}
+
+	path, dumpErr := h.dumper.write(err, c.Request())
+	if dumpErr != nil {
+		c.Log(fmt.Sprintf("[apierr-handler] can't write crash dump: %v", dumpErr))
+		return ""
+	}
+
+	return path
+}
+
+// Make report message for the error.
+func (h *Handler) makeReport(err interface{}, fail *apierr.APIError) string {
+	messages := make([]string, 0)
+
+	messages = append(messages, fmt.Sprintf("[apierr.APIError] %+v [%+v]", err, fail.Context))
+	if h.needToAddTrace(fail) {
+		messages = append(messages, fmt.Sprintf("--> %+v", h.thrower()))
+		messages = append(messages, string(debug.Stack()))
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Wrap every reporter into its own circuit breaker.
+func (h *Handler) wrapReporters(reporters []Reporter) []Reporter {
+	wrapped := make([]Reporter, len(reporters))
+
+	for i, reporter := range reporters {
+		wrapped[i] = newBreaker(fmt.Sprintf("reporter #%d", i), reporter, h.Config.Breaker, h.Config.Logger)
+	}
+
+	return wrapped
+}
+
+// Send report to the reporters queue or to the log.
+func (h *Handler) report(report *Report, c adapter.Context) {
+	if h.queue == nil {
+		c.Log(report.Message)
+		return
+	}
+
+	h.queue.push(report)
+}
+
+// Flush waits until all queued reports are sent.
+func (h *Handler) Flush(ctx context.Context) error {
+	if h.queue == nil {
+		return nil
+	}
+
+	return h.queue.flush(ctx)
+}
+
+// Close stops accepting new reports and drains the queue.
+// Call it on application shutdown.
+func (h *Handler) Close(ctx context.Context) error {
+	if h.queue == nil {
+		return nil
+	}
+
+	return h.queue.close(ctx)
+}
+
+// Dropped returns the number of reports dropped because the queue was full or closed.
+func (h *Handler) Dropped() uint64 {
+	if h.queue == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&h.queue.dropped)
+}
+
 // Converts catched error to internal apierr.APIError instance.
 func (h *Handler) convertToAPIError(err interface{}) *apierr.APIError {
 	var fail *apierr.APIError
@@ -63,8 +306,10 @@ func (h *Handler) convertToAPIError(err interface{}) *apierr.APIError {
 	switch err := err.(type) {
 	case *apierr.APIError:
 		fail = err
+	case *PanicError:
+		fail = err.Fail
 	case error:
-		fail = h.NewAPIError(err.(error))
+		fail = h.convertError(err)
 	case string:
 		fail = h.NewAPIError(errors.New(err))
 	default:
@@ -74,8 +319,45 @@ func (h *Handler) convertToAPIError(err interface{}) *apierr.APIError {
 	return fail
 }
 
+// Convert error with the registered converters.
+func (h *Handler) convertError(err error) *apierr.APIError {
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		return panicErr.Fail
+	}
+
+	var injectedErr *InjectedError
+	if errors.As(err, &injectedErr) {
+		return injectedErr.Fail
+	}
+
+	if errs := joinedErrors(err); len(errs) > 1 {
+		return h.aggregate(err, errs)
+	}
+
+	// Wrapped APIError, like the indexed one.
+	var fail *apierr.APIError
+	if errors.As(err, &fail) {
+		return fail
+	}
+
+	for _, converter := range h.Config.Converters {
+		if fail := converter(err); fail != nil {
+			return fail
+		}
+	}
+
+	return h.NewAPIError(err)
+}
+
 // NewAPIError makes new API error.
+// Errors implementing StatusCoder, HTTPStatuser, ErrorIDer or PublicMessager keep
+// their status, id and public message even in production.
 func (h *Handler) NewAPIError(err error) *apierr.APIError {
+	if fail := h.fromInterfaces(err); fail != nil {
+		return fail
+	}
+
 	// Don't show unknown error text to user when in production.
 	if h.isProduction() {
 		return apierr.InternalServerError
@@ -112,16 +394,28 @@ func (h *Handler) isDebugOn() bool {
 }
 
 // Return a possible line, where panic was thrown.
+// It is the first frame after runtime.gopanic, or the first app frame if nothing panicked.
 func (h *Handler) thrower() string {
-	var goSrcRegexp = regexp.MustCompile(`(mlanin/apierr-handler/.*.go)|(libexec/src/runtime)`)
-	var goTestRegexp = regexp.MustCompile(`mlanin/apierr-handler/.*test.go`)
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	first := ""
+	panicked := false
+
+	for {
+		frame, more := frames.Next()
 
-	for i := 2; i < 15; i++ {
-		_, file, line, ok := runtime.Caller(i)
-		if ok && (!goSrcRegexp.MatchString(file) || goTestRegexp.MatchString(file)) {
-			return fmt.Sprintf("%v:%v", file, line)
+		switch {
+		case frame.Function == "runtime.gopanic":
+			panicked = true
+		case panicked && !strings.HasPrefix(frame.Function, "runtime."):
+			return fmt.Sprintf("%v:%v", frame.File, frame.Line)
+		case first == "" && (isAppFile(frame.File) || strings.HasSuffix(frame.File, "_test.go")):
+			first = fmt.Sprintf("%v:%v", frame.File, frame.Line)
 		}
-	}
 
-	return ""
+		if !more {
+			return first
+		}
+	}
 }
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/kataras/iris/v12"
+	"github.com/mlanin/go-apierr"
+	"github.com/mlanin/iris-middlewares/internal/adapter"
+)
+
+// Transformer rewrites APIError before it is reported and rendered.
+// Returning nil keeps the error untouched.
+// Context is nil when the error came through the net/http adapter.
+type Transformer func(fail *apierr.APIError, ctx iris.Context) *apierr.APIError
+
+// RenderHook is called right before APIError is sent to the user.
+// Context is nil when the error came through the net/http adapter.
+type RenderHook func(fail *apierr.APIError, ctx iris.Context)
+
+// ReportHook is called after APIError was reported.
+type ReportHook func(fail *apierr.APIError, message string)
+
+// Transform registers transformer to run after the panic was converted to APIError.
+func (h *Handler) Transform(transformer Transformer) {
+	h.transformers = append(h.transformers, transformer)
+}
+
+// BeforeRender registers hook to run right before the response is sent.
+func (h *Handler) BeforeRender(hook RenderHook) {
+	h.renderHooks = append(h.renderHooks, hook)
+}
+
+// AfterReport registers hook to run after the error was reported.
+func (h *Handler) AfterReport(hook ReportHook) {
+	h.reportHooks = append(h.reportHooks, hook)
+}
+
+// Run transformers in registration order.
+func (h *Handler) transform(fail *apierr.APIError, c adapter.Context) *apierr.APIError {
+	if len(h.transformers) == 0 {
+		return fail
+	}
+
+	// Predefined errors are shared, so never let transformers change them.
+	clone := *fail
+	fail = &clone
+
+	for _, transformer := range h.transformers {
+		h.safely("transformer", c, func() {
+			if transformed := transformer(fail, c.Iris()); transformed != nil {
+				fail = transformed
+			}
+		})
+	}
+
+	return fail
+}
+
+// Run render hooks in registration order.
+func (h *Handler) beforeRender(fail *apierr.APIError, c adapter.Context) {
+	for _, hook := range h.renderHooks {
+		h.safely("before render", c, func() {
+			hook(fail, c.Iris())
+		})
+	}
+}
+
+// Run report hooks in registration order.
+func (h *Handler) afterReport(fail *apierr.APIError, message string, c adapter.Context) {
+	for _, hook := range h.reportHooks {
+		h.safely("after report", c, func() {
+			hook(fail, message)
+		})
+	}
+}
+
+// Call hook and report its panic instead of breaking the response.
+func (h *Handler) safely(name string, c adapter.Context, hook func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			c.Log(fmt.Sprintf("[apierr-handler] %s hook panicked: %+v\n%s", name, err, debug.Stack()))
+		}
+	}()
+
+	hook()
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/mlanin/iris-middlewares/internal/adapter"
+)
+
+// Wrap makes net/http middleware that handles panics the same way Serve does.
+// Hooks receive nil Iris context for these requests.
+func (h *Handler) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := h.lockoutKey(nil, r)
+		if retryAfter := h.lockedOut(key); retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+			h.handle(TooManyRequests, adapter.FromHTTP(w, r, h.Config.Logger))
+			return
+		}
+
+		if h.budget != nil || h.lockout != nil {
+			recorder := &statusRecorder{ResponseWriter: w}
+			defer h.trackHTTP(recorder, r, key)
+			w = recorder
+		}
+
+		defer func() {
+			if err := recover(); err != nil {
+				// Let net/http abort the response silently.
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+
+				h.handle(err, adapter.FromHTTP(w, r, h.Config.Logger))
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandleHTTP sends error through the same pipeline as recovered panics for net/http requests.
+func (h *Handler) HandleHTTP(w http.ResponseWriter, r *http.Request, err interface{}) {
+	h.handle(err, adapter.FromHTTP(w, r, h.Config.Logger))
+}
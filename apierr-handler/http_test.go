@@ -0,0 +1,45 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+)
+
+func TestItWrapsNetHTTPHandler(t *testing.T) {
+	errorsHandler := handler.New(handler.Config{
+		EnvGetter: func() string {
+			return "production"
+		},
+		DebugGetter: func() bool {
+			return false
+		},
+	})
+
+	server := errorsHandler.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(apierr.NotFound)
+	}))
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Error("Expected 404, got", w.Code)
+	}
+
+	var body struct {
+		Error struct {
+			ID string `json:"id"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error.ID != "not_found" {
+		t.Error("Expected not_found, got", body.Error.ID)
+	}
+}
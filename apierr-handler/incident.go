@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/mlanin/go-apierr"
+)
+
+// Key of the incident ID in the response meta.
+const incidentKey = "incident_id"
+
+// Make unique incident ID.
+func newIncidentID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+// Check if user needs incident ID to quote to support.
+func (h *Handler) needIncident(fail *apierr.APIError) bool {
+	return fail.HTTPCode >= http.StatusInternalServerError && h.needToReport(fail)
+}
+
+// Add incident ID to the meta of the response body.
+// If meta is not an object, ID is added next to it. Returns false if body is not an object.
+func withIncident(body interface{}, incidentID string) (interface{}, bool) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return body, false
+	}
+
+	var object map[string]interface{}
+	if json.Unmarshal(data, &object) != nil || object == nil {
+		return body, false
+	}
+
+	meta, ok := object["meta"].(map[string]interface{})
+	if !ok {
+		if object["meta"] != nil {
+			object[incidentKey] = incidentID
+			return object, true
+		}
+		meta = make(map[string]interface{})
+	}
+
+	meta[incidentKey] = incidentID
+	object["meta"] = meta
+
+	return object, true
+}
@@ -0,0 +1,83 @@
+package handler_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+)
+
+func TestItReturnsIncidentIDForServerErrors(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/panic", func(ctx iris.Context) {
+		panic("database is on fire")
+	})
+	api.Get("/missing", func(ctx iris.Context) {
+		panic(apierr.NotFound)
+	})
+
+	e := httptest.New(t, api)
+
+	resp := e.GET("/panic").Expect()
+	apierrtest.AssertAPIError(t, resp, "internal_server_error", 500)
+
+	incidentID := resp.JSON().Object().Value("meta").Object().Value("incident_id").String().Raw()
+	if len(incidentID) != 16 {
+		t.Fatal("Expected incident ID of 16 chars, got", incidentID)
+	}
+
+	reports := errorsHandler.Reports()
+	if len(reports) != 1 {
+		t.Fatal("Expected 1 report, got", len(reports))
+	}
+	if reports[0].IncidentID != incidentID {
+		t.Error("Expected report incident to be", incidentID, "got", reports[0].IncidentID)
+	}
+	if !strings.Contains(reports[0].Message, incidentID) {
+		t.Error("Expected incident ID in report message, got", reports[0].Message)
+	}
+
+	second := e.GET("/panic").Expect().JSON().Object().Value("meta").Object().Value("incident_id").String().Raw()
+	if second == incidentID {
+		t.Error("Expected new incident ID, got", second)
+	}
+
+	resp = e.GET("/missing").Expect()
+	apierrtest.AssertAPIError(t, resp, "not_found", 404)
+	resp.JSON().Object().NotContainsKey("meta")
+}
+
+func TestItKeepsIncidentIDWhenMetaIsNotObject(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/panic", func(ctx iris.Context) {
+		fail := *apierr.InternalServerError
+		fail.AddMeta([]string{"database"})
+
+		panic(&fail)
+	})
+
+	e := httptest.New(t, api)
+	object := e.GET("/panic").Expect().Status(500).JSON().Object()
+
+	object.Value("meta").Array().Elements("database")
+
+	reports := errorsHandler.Reports()
+	if len(reports) != 1 {
+		t.Fatal("Expected 1 report, got", len(reports))
+	}
+	object.ValueEqual("incident_id", reports[0].IncidentID)
+}
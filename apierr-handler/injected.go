@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/kataras/iris/v12"
+	"github.com/mlanin/go-apierr"
+	"github.com/mlanin/iris-middlewares/internal/adapter"
+)
+
+// Marks the Iris requests that are not counted in the budget and lockout.
+const untrackedKey = "apierr-handler.untracked"
+
+// InjectedError is the error injected on purpose, like by the fault injection.
+// It is rendered as usual, but is not reported, audited or recorded on the span and gets no incident ID.
+type InjectedError struct {
+	Fail *apierr.APIError
+}
+
+// Error message.
+func (e *InjectedError) Error() string {
+	return "injected " + e.Fail.Error()
+}
+
+// Inject answers with the injected error. The request is not counted in the budget and lockout.
+func (h *Handler) Inject(ctx iris.Context, fail *apierr.APIError) {
+	Untrack(ctx)
+	h.handle(injected(fail), adapter.FromIris(ctx))
+}
+
+// InjectHTTP answers net/http request with the injected error.
+func (h *Handler) InjectHTTP(w http.ResponseWriter, r *http.Request, fail *apierr.APIError) {
+	UntrackHTTP(w)
+	h.handle(injected(fail), adapter.FromHTTP(w, r, h.Config.Logger))
+}
+
+// Untrack excludes the Iris request from the budget and lockout.
+func Untrack(ctx iris.Context) {
+	ctx.Values().Set(untrackedKey, true)
+}
+
+// UntrackHTTP excludes the net/http request from the budget and lockout.
+// Writer must be the one passed by Wrap or wrap it.
+func UntrackHTTP(w http.ResponseWriter) {
+	if recorder := findStatusRecorder(w); recorder != nil {
+		recorder.untracked = true
+	}
+}
+
+// Make injected copy of the error that is never reported.
+func injected(fail *apierr.APIError) *InjectedError {
+	clone := *fail
+	clone.ShouldReport = false
+
+	return &InjectedError{Fail: &clone}
+}
+
+// Check if error is injected.
+func isInjected(err interface{}) bool {
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+
+	var injectedErr *InjectedError
+
+	return errors.As(e, &injectedErr)
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mlanin/go-apierr"
+)
+
+// StatusCoder is implemented by errors that know their HTTP status.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// HTTPStatuser is the alternative of StatusCoder.
+type HTTPStatuser interface {
+	HTTPStatus() int
+}
+
+// ErrorIDer is implemented by errors that know their id.
+type ErrorIDer interface {
+	ErrorID() string
+}
+
+// PublicMessager is implemented by errors with message safe to show in production.
+type PublicMessager interface {
+	PublicMessage() string
+}
+
+// Make APIError from error implementing any of the known interfaces.
+func (h *Handler) fromInterfaces(err error) *apierr.APIError {
+	code := 0
+	id := ""
+	message := ""
+	public := false
+
+	var statusCoder StatusCoder
+	var httpStatuser HTTPStatuser
+	var errorIDer ErrorIDer
+	var publicMessager PublicMessager
+
+	if errors.As(err, &statusCoder) {
+		code = statusCoder.StatusCode()
+	} else if errors.As(err, &httpStatuser) {
+		code = httpStatuser.HTTPStatus()
+	}
+	if errors.As(err, &errorIDer) {
+		id = errorIDer.ErrorID()
+	}
+	if errors.As(err, &publicMessager) {
+		message = publicMessager.PublicMessage()
+		public = true
+	}
+
+	if code == 0 && id == "" && !public {
+		return nil
+	}
+
+	if code < 400 || code > 599 {
+		code = http.StatusInternalServerError
+	}
+	if id == "" {
+		id = statusID(code)
+	}
+	if !public {
+		// Don't show unknown error text to user when in production.
+		if h.isProduction() {
+			message = http.StatusText(code) + "."
+		} else {
+			message = err.Error()
+		}
+	}
+
+	return &apierr.APIError{
+		Body: apierr.Body{
+			ID:      id,
+			Message: message,
+		},
+		HTTPCode:     code,
+		ShouldReport: code >= 500,
+	}
+}
+
+// Make id from the status text, like not_found.
+func statusID(code int) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(http.StatusText(code)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-':
+			b.WriteByte('_')
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,48 @@
+package handler_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+)
+
+type quotaError struct{}
+
+func (e *quotaError) Error() string         { return "quota of account 42 exceeded" }
+func (e *quotaError) StatusCode() int       { return 429 }
+func (e *quotaError) ErrorID() string       { return "quota_exceeded" }
+func (e *quotaError) PublicMessage() string { return "Quota exceeded." }
+
+type conflictError struct{}
+
+func (e *conflictError) Error() string   { return "row 42 is locked" }
+func (e *conflictError) HTTPStatus() int { return 409 }
+
+func TestItUsesErrorInterfaces(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/quota", func(ctx iris.Context) {
+		panic(fmt.Errorf("wrapped: %w", &quotaError{}))
+	})
+	api.Get("/conflict", func(ctx iris.Context) {
+		panic(&conflictError{})
+	})
+
+	e := httptest.New(t, api)
+
+	resp := e.GET("/quota").Expect()
+	apierrtest.AssertAPIError(t, resp, "quota_exceeded", 429)
+	resp.JSON().Object().Value("error").Object().ValueEqual("message", "Quota exceeded.")
+
+	resp = e.GET("/conflict").Expect()
+	apierrtest.AssertAPIError(t, resp, "conflict", 409)
+	resp.JSON().Object().Value("error").Object().ValueEqual("message", "Conflict.")
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/mlanin/go-apierr"
+)
+
+const (
+	defaultLockoutWindow   = 15 * time.Minute
+	defaultLockoutCoolDown = 15 * time.Minute
+	lockoutSweepEvery      = 1024
+)
+
+// TooManyRequests is sent to the locked out clients.
+var TooManyRequests = &apierr.APIError{
+	Body: apierr.Body{
+		ID:      "too_many_requests",
+		Message: "Too many requests.",
+	},
+	HTTPCode: http.StatusTooManyRequests,
+}
+
+// LockoutStore keeps failures and locks of the clients.
+// Implement it to share lockouts between nodes.
+type LockoutStore interface {
+	// Fail adds failure of the key and returns the number of its failures within the window.
+	Fail(key string, window time.Duration, now time.Time) (int, error)
+	// Lock locks the key until the given time.
+	Lock(key string, until time.Time) error
+	// LockedUntil returns time until the key is locked or zero time.
+	LockedUntil(key string, now time.Time) (time.Time, error)
+}
+
+// LockoutConfig for the failure based lockout.
+type LockoutConfig struct {
+	// Number of failures in the window to lock the client. Lockout is off if zero.
+	Threshold int
+	// Sliding window of the failures. Defaults to 15 minutes.
+	Window time.Duration
+	// How long the client is locked. Defaults to 15 minutes.
+	CoolDown time.Duration
+	// Statuses counted as failures. Defaults to 401, 403 and 422.
+	Statuses []int
+	// Returns key of the client, like username. Defaults to client IP.
+	// Empty key turns lockout off for the request.
+	// Getter runs before the handlers, so it must not read bodies they need.
+	// Iris context is nil for net/http requests.
+	KeyGetter func(ctx iris.Context, r *http.Request) string
+	// Combine the key of KeyGetter with the client IP. Then nobody can lock out other users
+	// by failing with their names, but attempts spread over many IPs are not locked out.
+	ScopeByIP bool
+	// Where failures and locks are kept. Defaults to the memory.
+	Store LockoutStore
+}
+
+// Failures and lock of the single key.
+type lockoutEntry struct {
+	failures []time.Time
+	until    time.Time
+}
+
+// Store keeping everything in the memory of the node.
+type memoryLockoutStore struct {
+	mu      sync.Mutex
+	entries map[string]*lockoutEntry
+	calls   int
+}
+
+// NewMemoryLockoutStore makes store keeping failures in the memory of the node.
+func NewMemoryLockoutStore() LockoutStore {
+	return &memoryLockoutStore{entries: make(map[string]*lockoutEntry)}
+}
+
+// Fail adds failure and drops the ones out of the window.
+func (s *memoryLockoutStore) Fail(key string, window time.Duration, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if s.calls%lockoutSweepEvery == 0 {
+		s.sweep(window, now)
+	}
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &lockoutEntry{}
+		s.entries[key] = entry
+	}
+
+	entry.failures = append(prune(entry.failures, now.Add(-window)), now)
+
+	return len(entry.failures), nil
+}
+
+// Lock the key.
+func (s *memoryLockoutStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &lockoutEntry{}
+		s.entries[key] = entry
+	}
+
+	entry.until = until
+	entry.failures = nil
+
+	return nil
+}
+
+// LockedUntil returns end of the lock.
+func (s *memoryLockoutStore) LockedUntil(key string, now time.Time) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && entry.until.After(now) {
+		return entry.until, nil
+	}
+
+	return time.Time{}, nil
+}
+
+// Remove keys without recent failures and locks.
+func (s *memoryLockoutStore) sweep(window time.Duration, now time.Time) {
+	for key, entry := range s.entries {
+		entry.failures = prune(entry.failures, now.Add(-window))
+		if len(entry.failures) == 0 && !entry.until.After(now) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Drop failures older than the given time.
+func prune(failures []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(failures) && !failures[i].After(since) {
+		i++
+	}
+
+	return failures[i:]
+}
+
+// Fill lockout defaults if it is configured.
+func newLockout(config LockoutConfig) *LockoutConfig {
+	if config.Threshold <= 0 {
+		return nil
+	}
+	if config.Window <= 0 {
+		config.Window = defaultLockoutWindow
+	}
+	if config.CoolDown <= 0 {
+		config.CoolDown = defaultLockoutCoolDown
+	}
+	if len(config.Statuses) == 0 {
+		config.Statuses = []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusUnprocessableEntity}
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryLockoutStore()
+	}
+
+	return &config
+}
+
+// Get lockout key of the client.
+func (h *Handler) lockoutKey(ctx iris.Context, r *http.Request) string {
+	if h.lockout == nil {
+		return ""
+	}
+	if h.lockout.KeyGetter == nil {
+		return h.clientIP(r)
+	}
+
+	key := h.lockout.KeyGetter(ctx, r)
+	if key == "" || !h.lockout.ScopeByIP {
+		return key
+	}
+
+	return h.clientIP(r) + " " + key
+}
+
+// Return Retry-After value if the client is locked out or empty string.
+func (h *Handler) lockedOut(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	now := time.Now()
+
+	until, err := h.lockout.Store.LockedUntil(key, now)
+	if err != nil {
+		h.Config.Logger.Printf("can't check lockout of %q: %v", key, err)
+		return ""
+	}
+	if !until.After(now) {
+		return ""
+	}
+
+	seconds := int((until.Sub(now) + time.Second - 1) / time.Second)
+
+	return strconv.Itoa(seconds)
+}
+
+// Count failure of the client and lock it after the threshold.
+func (h *Handler) countFailure(key string, status int) {
+	if key == "" || !h.isLockoutStatus(status) {
+		return
+	}
+
+	now := time.Now()
+
+	failures, err := h.lockout.Store.Fail(key, h.lockout.Window, now)
+	if err != nil {
+		h.Config.Logger.Printf("can't count failure of %q: %v", key, err)
+		return
+	}
+	if failures < h.lockout.Threshold {
+		return
+	}
+
+	if err := h.lockout.Store.Lock(key, now.Add(h.lockout.CoolDown)); err != nil {
+		h.Config.Logger.Printf("can't lock %q: %v", key, err)
+	}
+}
+
+// Check if the status is counted as failure.
+func (h *Handler) isLockoutStatus(status int) bool {
+	for _, s := range h.lockout.Statuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,95 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+)
+
+func loginAPI(t *testing.T, scopeByIP bool) *iris.Application {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		Lockout: handler.LockoutConfig{
+			Threshold: 3,
+			CoolDown:  time.Minute,
+			KeyGetter: func(ctx iris.Context, r *http.Request) string {
+				return r.Header.Get("X-User")
+			},
+			ScopeByIP: scopeByIP,
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Post("/login", func(ctx iris.Context) {
+		if ctx.GetHeader("X-Password") != "secret" {
+			ctx.StatusCode(iris.StatusUnauthorized)
+		}
+	})
+
+	return api
+}
+
+// Send request from the IP.
+func fromIP(ip string) func(r *http.Request) {
+	return func(r *http.Request) {
+		r.RemoteAddr = ip + ":1234"
+	}
+}
+
+func TestItLocksOutClientsAfterFailures(t *testing.T) {
+	e := httptest.New(t, loginAPI(t, false))
+
+	// Attempts spread over several IPs.
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		e.POST("/login").WithHeader("X-User", "john").WithTransformer(fromIP(ip)).Expect().Status(iris.StatusUnauthorized)
+	}
+
+	resp := e.POST("/login").WithHeader("X-User", "john").WithHeader("X-Password", "secret").WithTransformer(fromIP("192.0.2.4")).Expect()
+	apierrtest.AssertAPIError(t, resp, "too_many_requests", 429)
+	resp.Header("Retry-After").Equal("60")
+
+	e.POST("/login").WithHeader("X-User", "jane").WithHeader("X-Password", "secret").Expect().Status(iris.StatusOK)
+}
+
+func TestItScopesLockoutByIP(t *testing.T) {
+	e := httptest.New(t, loginAPI(t, true))
+
+	for i := 0; i < 3; i++ {
+		e.POST("/login").WithHeader("X-User", "john").WithTransformer(fromIP("192.0.2.1")).Expect().Status(iris.StatusUnauthorized)
+	}
+
+	resp := e.POST("/login").WithHeader("X-User", "john").WithHeader("X-Password", "secret").WithTransformer(fromIP("192.0.2.1")).Expect()
+	apierrtest.AssertAPIError(t, resp, "too_many_requests", 429)
+
+	e.POST("/login").WithHeader("X-User", "john").WithHeader("X-Password", "secret").WithTransformer(fromIP("192.0.2.2")).
+		Expect().
+		Status(iris.StatusOK)
+}
+
+func TestMemoryLockoutStoreUsesSlidingWindow(t *testing.T) {
+	store := handler.NewMemoryLockoutStore()
+	now := time.Now()
+
+	store.Fail("john", time.Minute, now)
+	store.Fail("john", time.Minute, now.Add(30*time.Second))
+
+	failures, _ := store.Fail("john", time.Minute, now.Add(80*time.Second))
+	if failures != 2 {
+		t.Error("Expected 2 failures in the window, got", failures)
+	}
+
+	store.Lock("john", now.Add(time.Minute))
+	if until, _ := store.LockedUntil("john", now); !until.Equal(now.Add(time.Minute)) {
+		t.Error("Expected john to be locked, got", until)
+	}
+	if until, _ := store.LockedUntil("john", now.Add(2*time.Minute)); !until.IsZero() {
+		t.Error("Expected lock to expire, got", until)
+	}
+}
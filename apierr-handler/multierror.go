@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mlanin/go-apierr"
+)
+
+// StatusRule chooses HTTP status of the response with several errors.
+type StatusRule int
+
+const (
+	// HighestStatus uses the highest status of the errors.
+	HighestStatus StatusRule = iota
+	// MostCommonStatus uses the status most of the errors have.
+	// Ties are resolved in favour of the higher status.
+	MostCommonStatus
+	// MultiStatus always answers with 207 Multi-Status.
+	MultiStatus
+)
+
+// MultiErrors is the meta of the response with several errors.
+type MultiErrors struct {
+	Errors []MultiErrorItem `json:"errors"`
+}
+
+// MultiErrorItem is one of the several errors.
+type MultiErrorItem struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	Status  int    `json:"status"`
+	Index   *int   `json:"index,omitempty"`
+}
+
+// IndexedError is the error of the batch item.
+type IndexedError struct {
+	Index int
+	Err   error
+}
+
+// AtIndex marks error as the error of the batch item with the given index.
+func AtIndex(index int, err error) error {
+	return &IndexedError{Index: index, Err: err}
+}
+
+// Error message with the index of the item.
+func (e *IndexedError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns error of the item.
+func (e *IndexedError) Unwrap() error {
+	return e.Err
+}
+
+// Get errors joined with errors.Join or fmt.Errorf with several %w.
+// Joined errors are looked for down the wrap chain, like in fmt.Errorf("batch: %w", errors.Join(...)).
+func joinedErrors(err error) []error {
+	for err != nil {
+		if multi, ok := err.(interface{ Unwrap() []error }); ok {
+			return multi.Unwrap()
+		}
+
+		err = errors.Unwrap(err)
+	}
+
+	return nil
+}
+
+// Make one APIError with the list of all joined errors.
+func (h *Handler) aggregate(err error, errs []error) *apierr.APIError {
+	items := make([]MultiErrorItem, 0, len(errs))
+	report := false
+
+	for _, e := range errs {
+		fail := h.convertToAPIError(e)
+
+		item := MultiErrorItem{
+			ID:      fail.Body.ID,
+			Message: fail.Body.Message,
+			Status:  fail.HTTPCode,
+		}
+
+		var indexed *IndexedError
+		if errors.As(e, &indexed) {
+			index := indexed.Index
+			item.Index = &index
+		}
+
+		items = append(items, item)
+		report = report || fail.WantsToBeReported()
+	}
+
+	code := h.multiStatus(items)
+
+	id := "multiple_errors"
+	if code == http.StatusMultiStatus {
+		id = statusID(code)
+	}
+
+	fail := &apierr.APIError{
+		Body: apierr.Body{
+			ID:      id,
+			Message: fmt.Sprintf("%d errors occurred.", len(items)),
+		},
+		HTTPCode:     code,
+		ShouldReport: report,
+	}
+	fail.AddMeta(&MultiErrors{
+		Errors: items,
+	})
+	fail.AddContext(err.Error())
+
+	return fail
+}
+
+// Choose status of the response by the configured rule.
+func (h *Handler) multiStatus(items []MultiErrorItem) int {
+	switch h.Config.MultiErrorStatus {
+	case MultiStatus:
+		return http.StatusMultiStatus
+	case MostCommonStatus:
+		counts := make(map[int]int)
+		code := 0
+
+		for _, item := range items {
+			counts[item.Status]++
+
+			if counts[item.Status] > counts[code] || (counts[item.Status] == counts[code] && item.Status > code) {
+				code = item.Status
+			}
+		}
+
+		return code
+	}
+
+	code := 0
+	for _, item := range items {
+		if item.Status > code {
+			code = item.Status
+		}
+	}
+
+	return code
+}
@@ -0,0 +1,84 @@
+package handler_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+)
+
+func batchAPI(t *testing.T, rule handler.StatusRule) *iris.Application {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		MultiErrorStatus: rule,
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Post("/batch", func(ctx iris.Context) {
+		panic(errors.Join(
+			handler.AtIndex(0, apierr.NotFound),
+			handler.AtIndex(2, apierr.NotFound),
+			&conflictError{},
+		))
+	})
+
+	return api
+}
+
+func TestItAggregatesJoinedErrors(t *testing.T) {
+	e := httptest.New(t, batchAPI(t, handler.HighestStatus))
+
+	resp := e.POST("/batch").Expect()
+	apierrtest.AssertAPIError(t, resp, "multiple_errors", 409)
+
+	items := resp.JSON().Object().Value("meta").Object().Value("errors").Array()
+	items.Length().Equal(3)
+
+	first := items.Element(0).Object()
+	first.ValueEqual("id", "not_found")
+	first.ValueEqual("status", 404)
+	first.ValueEqual("index", 0)
+
+	items.Element(1).Object().ValueEqual("index", 2)
+
+	last := items.Element(2).Object()
+	last.ValueEqual("id", "conflict")
+	last.ValueEqual("status", 409)
+	last.NotContainsKey("index")
+}
+
+func TestItChoosesStatusOfJoinedErrors(t *testing.T) {
+	e := httptest.New(t, batchAPI(t, handler.MostCommonStatus))
+	apierrtest.AssertAPIError(t, e.POST("/batch").Expect(), "multiple_errors", 404)
+
+	e = httptest.New(t, batchAPI(t, handler.MultiStatus))
+	apierrtest.AssertAPIError(t, e.POST("/batch").Expect(), "multi_status", 207)
+}
+
+func TestItAggregatesWrappedJoinedErrors(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Post("/batch", func(ctx iris.Context) {
+		panic(fmt.Errorf("batch: %w", errors.Join(
+			handler.AtIndex(0, apierr.NotFound),
+			handler.AtIndex(1, apierr.BadRequest),
+		)))
+	})
+
+	e := httptest.New(t, api)
+
+	resp := e.POST("/batch").Expect()
+	apierrtest.AssertAPIError(t, resp, "multiple_errors", 404)
+	resp.JSON().Object().Value("meta").Object().Value("errors").Array().Length().Equal(2)
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DropPolicy decides which report to drop when the queue is full.
+type DropPolicy int
+
+const (
+	// DropNewest discards report that doesn't fit into the queue.
+	DropNewest DropPolicy = iota
+	// DropOldest discards the oldest waiting report to make room for the new one.
+	DropOldest
+)
+
+const (
+	defaultQueueSize = 1024
+	defaultWorkers   = 1
+	flushInterval    = 10 * time.Millisecond
+)
+
+// Bounded reports queue processed by the workers pool.
+type queue struct {
+	reports   chan *Report
+	reporters []Reporter
+	policy    DropPolicy
+	logger    *log.Logger
+
+	pending int64
+	dropped uint64
+
+	mu      sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// Make queue and start its workers.
+func newQueue(cfg Config, reporters []Reporter) *queue {
+	size := cfg.QueueSize
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	q := &queue{
+		reports:   make(chan *Report, size),
+		reporters: reporters,
+		policy:    cfg.DropPolicy,
+		logger:    cfg.Logger,
+	}
+
+	q.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+
+	return q
+}
+
+// Put report to the queue without blocking.
+func (q *queue) push(report *Report) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		atomic.AddUint64(&q.dropped, 1)
+		return
+	}
+
+	atomic.AddInt64(&q.pending, 1)
+	if q.offer(report) {
+		return
+	}
+
+	if q.policy == DropOldest {
+		select {
+		case <-q.reports:
+			atomic.AddInt64(&q.pending, -1)
+			atomic.AddUint64(&q.dropped, 1)
+		default:
+		}
+
+		if q.offer(report) {
+			return
+		}
+	}
+
+	atomic.AddInt64(&q.pending, -1)
+	atomic.AddUint64(&q.dropped, 1)
+}
+
+// Try to put report to the buffer.
+func (q *queue) offer(report *Report) bool {
+	select {
+	case q.reports <- report:
+		return true
+	default:
+		return false
+	}
+}
+
+// Process reports until the queue is closed.
+func (q *queue) work() {
+	defer q.workers.Done()
+
+	for report := range q.reports {
+		for _, reporter := range q.reporters {
+			q.deliver(reporter, report)
+		}
+		atomic.AddInt64(&q.pending, -1)
+	}
+}
+
+// Send report and swallow reporter failures.
+func (q *queue) deliver(reporter Reporter, report *Report) {
+	defer func() {
+		if err := recover(); err != nil {
+			q.logger.Printf("reporter panicked: %+v", err)
+		}
+	}()
+
+	if err := reporter.Report(report); err != nil {
+		q.logger.Printf("reporter failed: %v", err)
+	}
+}
+
+// Wait until all queued reports are processed.
+func (q *queue) flush(ctx context.Context) error {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for atomic.LoadInt64(&q.pending) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// Stop accepting reports and wait for workers to drain the queue.
+func (q *queue) close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.reports)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
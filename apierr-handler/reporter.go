@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"log"
+	"time"
+
+	"github.com/mlanin/go-apierr"
+)
+
+// Report of the single error.
+type Report struct {
+	// Unique ID of the 5xx error, also sent to the user.
+	IncidentID string
+	// Converted error.
+	Error *apierr.APIError
+	// Original recovered value.
+	Panic interface{}
+	// Formatted message with trace.
+	Message string
+	// Request method, URL and route.
+	Method string
+	URL    string
+	Route  string
+	// ID of the request if known.
+	RequestID string
+	// Resolved client IP.
+	ClientIP string
+	// Path to the crash dump if it was written.
+	DumpFile string
+	// Runtime diagnostics if they were collected.
+	Diagnostics string
+	// When error happened.
+	Time time.Time
+}
+
+// Reporter sends reports to the external sink.
+type Reporter interface {
+	Report(report *Report) error
+}
+
+// ReporterFunc allows to use ordinary functions as reporters.
+type ReporterFunc func(report *Report) error
+
+// Report calls f(report).
+func (f ReporterFunc) Report(report *Report) error {
+	return f(report)
+}
+
+// NewLogReporter makes reporter that writes messages to the logger.
+func NewLogReporter(logger *log.Logger) Reporter {
+	return ReporterFunc(func(report *Report) error {
+		logger.Println(report.Message)
+		return nil
+	})
+}
@@ -0,0 +1,121 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+)
+
+func TestItSendsReportsToReporters(t *testing.T) {
+	api := iris.New()
+
+	var mu sync.Mutex
+	reports := make([]*handler.Report, 0)
+
+	errorsHandler := handler.New(handler.Config{
+		EnvGetter: func() string {
+			return "production"
+		},
+		DebugGetter: func() bool {
+			return false
+		},
+		Reporters: []handler.Reporter{
+			handler.ReporterFunc(func(report *handler.Report) error {
+				mu.Lock()
+				defer mu.Unlock()
+				reports = append(reports, report)
+				return nil
+			}),
+			handler.ReporterFunc(func(report *handler.Report) error {
+				return errors.New("Sink is down")
+			}),
+			handler.ReporterFunc(func(report *handler.Report) error {
+				panic("Broken reporter")
+			}),
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		panic("Error")
+	})
+
+	e := httptest.New(t, api)
+	e.GET("/").
+		Expect().
+		Status(iris.StatusInternalServerError)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := errorsHandler.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(reports) != 1 {
+		t.Fatal("Expected 1 report, got", len(reports))
+	}
+	if reports[0].Error.Body.ID != "internal_server_error" || reports[0].URL != "/" {
+		t.Error("Unexpected report", reports[0])
+	}
+}
+
+func TestItDropsReportsWhenQueueIsFull(t *testing.T) {
+	api := iris.New()
+
+	release := make(chan struct{})
+
+	errorsHandler := handler.New(handler.Config{
+		EnvGetter: func() string {
+			return "production"
+		},
+		DebugGetter: func() bool {
+			return false
+		},
+		Reporters: []handler.Reporter{
+			handler.ReporterFunc(func(report *handler.Report) error {
+				<-release
+				return nil
+			}),
+		},
+		QueueSize: 1,
+		Workers:   1,
+	})
+
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		panic(apierr.InternalServerError)
+	})
+
+	e := httptest.New(t, api)
+	for i := 0; i < 3; i++ {
+		e.GET("/").
+			Expect().
+			Status(iris.StatusInternalServerError)
+	}
+
+	if errorsHandler.Dropped() == 0 {
+		t.Error("Expected some reports to be dropped")
+	}
+
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := errorsHandler.Flush(ctx); err != nil {
+		t.Error(err)
+	}
+}
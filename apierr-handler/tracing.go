@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mlanin/go-apierr"
+	"github.com/mlanin/iris-middlewares/internal/adapter"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Record error on the active OpenTelemetry span of the request.
+func (h *Handler) recordSpan(err interface{}, fail *apierr.APIError, c adapter.Context) {
+	span := trace.SpanFromContext(c.Request().Context())
+	if !span.IsRecording() {
+		return
+	}
+
+	attributes := []attribute.KeyValue{
+		attribute.String("apierr.id", fail.Body.ID),
+		attribute.Int("http.status_code", fail.HTTPCode),
+	}
+
+	// Thrower looks like file.go:42.
+	if thrower := h.thrower(); thrower != "" {
+		if i := strings.LastIndex(thrower, ":"); i > 0 {
+			line, _ := strconv.Atoi(thrower[i+1:])
+			attributes = append(attributes,
+				attribute.String("code.filepath", thrower[:i]),
+				attribute.Int("code.lineno", line),
+			)
+		}
+	}
+
+	span.RecordError(asError(err), trace.WithStackTrace(true), trace.WithAttributes(attributes...))
+
+	if fail.HTTPCode >= 500 {
+		span.SetStatus(codes.Error, fail.Body.Message)
+	}
+}
+
+// Make error from the recovered value.
+func asError(err interface{}) error {
+	if e, ok := err.(error); ok {
+		return e
+	}
+
+	return fmt.Errorf("%+v", err)
+}
@@ -0,0 +1,71 @@
+package handler_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestItRecordsErrorOnSpan(t *testing.T) {
+	api := iris.New()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+
+	api.Use(func(ctx iris.Context) {
+		spanCtx, span := tracer.Start(ctx.Request().Context(), "request")
+		defer span.End()
+
+		ctx.ResetRequest(ctx.Request().WithContext(spanCtx))
+		ctx.Next()
+	})
+	api.Use(errorsHandler.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		panic("Error")
+	})
+
+	e := httptest.New(t, api)
+	e.GET("/").
+		Expect().
+		Status(iris.StatusInternalServerError)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatal("Expected 1 span, got", len(spans))
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Error("Expected span status to be error")
+	}
+
+	events := spans[0].Events()
+	if len(events) != 1 || events[0].Name != "exception" {
+		t.Fatal("Expected exception event, got", events)
+	}
+
+	found := false
+	thrower := ""
+	for _, attr := range events[0].Attributes {
+		if attr == attribute.String("apierr.id", "internal_server_error") {
+			found = true
+		}
+		if attr.Key == "code.filepath" {
+			thrower = attr.Value.AsString()
+		}
+	}
+	if !found {
+		t.Error("Expected apierr.id attribute, got", events[0].Attributes)
+	}
+	if !strings.HasSuffix(thrower, "tracing_test.go") {
+		t.Error("Expected panic location in the test, got", thrower)
+	}
+}
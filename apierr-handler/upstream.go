@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/mlanin/go-apierr"
+)
+
+// Max size of the downstream error body.
+const maxUpstreamBody = 1 << 20
+
+// BadGateway is made when downstream service sends unknown error.
+var BadGateway = &apierr.APIError{
+	Body: apierr.Body{
+		ID:      "bad_gateway",
+		Message: "Bad gateway.",
+	},
+	HTTPCode:     http.StatusBadGateway,
+	ShouldReport: true,
+}
+
+// Downstream error in the go-apierr envelope.
+type upstreamBody struct {
+	Error *struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Meta json.RawMessage `json:"meta"`
+}
+
+// Origin of the downstream error for the report.
+type upstreamContext struct {
+	Upstream string `json:"upstream"`
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+}
+
+// FromResponse converts downstream error response with go-apierr envelope to APIError
+// preserving its id, message, meta and validation errors. Response body is read and closed.
+// Non-conforming responses become BadGateway.
+func FromResponse(resp *http.Response) *apierr.APIError {
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamBody))
+	if err != nil {
+		return badGateway()
+	}
+
+	var body upstreamBody
+	if err := json.Unmarshal(data, &body); err != nil || body.Error == nil || body.Error.ID == "" || resp.StatusCode < 400 {
+		return badGateway()
+	}
+
+	fail := &apierr.APIError{
+		Body: apierr.Body{
+			ID:      body.Error.ID,
+			Message: body.Error.Message,
+		},
+		HTTPCode:     resp.StatusCode,
+		ShouldReport: resp.StatusCode >= 500,
+	}
+
+	// Meta is kept as is, with validation errors and everything else.
+	if len(body.Meta) > 0 && string(body.Meta) != "null" {
+		fail.AddMeta(body.Meta)
+	}
+
+	return fail
+}
+
+// FromUpstream works like FromResponse and marks the error with the upstream name and URL,
+// so the report shows where it came from.
+func FromUpstream(name string, resp *http.Response) *apierr.APIError {
+	fail := FromResponse(resp)
+
+	url := ""
+	if resp.Request != nil {
+		url = resp.Request.URL.String()
+	}
+
+	fail.AddContext(&upstreamContext{
+		Upstream: name,
+		URL:      url,
+		Status:   resp.StatusCode,
+	})
+
+	return fail
+}
+
+// Copy of BadGateway error.
+func badGateway() *apierr.APIError {
+	fail := *BadGateway
+
+	return &fail
+}
@@ -0,0 +1,66 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+)
+
+func makeResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestItTranslatesDownstreamValidationError(t *testing.T) {
+	fail := handler.FromResponse(makeResponse(422, `{
+		"error": {"id": "validation_failed", "message": "Validation failed."},
+		"meta": {"errors": [{"field": "text", "message": "Cannot be blank"}], "incident_id": "abc"}
+	}`))
+
+	if fail.HTTPCode != 422 || fail.Body.ID != "validation_failed" {
+		t.Fatal("Unexpected error", fail)
+	}
+
+	data, err := json.Marshal(fail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body struct {
+		Meta struct {
+			apierr.ValidationErrors
+			IncidentID string `json:"incident_id"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Meta.Errors) != 1 || body.Meta.Errors[0].Field != "text" {
+		t.Error("Expected validation errors to be preserved, got", string(data))
+	}
+	if body.Meta.IncidentID != "abc" {
+		t.Error("Expected other meta to be preserved, got", string(data))
+	}
+}
+
+func TestItMakesBadGatewayForUnknownResponse(t *testing.T) {
+	for _, resp := range []*http.Response{
+		makeResponse(500, `<html>Oops</html>`),
+		makeResponse(404, `{"message": "Not found"}`),
+	} {
+		if fail := handler.FromUpstream("billing", resp); fail.Body.ID != "bad_gateway" || fail.HTTPCode != 502 {
+			t.Error("Expected bad_gateway, got", fail.Body.ID)
+		}
+	}
+
+	if handler.BadGateway.Context != nil {
+		t.Error("FromUpstream must not change predefined errors")
+	}
+}
@@ -0,0 +1,292 @@
+package fault
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+)
+
+const (
+	defaultHeader     = "X-Fault-Inject"
+	defaultMaxLatency = 30 * time.Second
+	// Header with the secret of the header faults.
+	secretHeader = "X-Fault-Secret"
+)
+
+// Fault to inject.
+type Fault struct {
+	// ID of the APIError to answer with, like "not_found".
+	Error string
+	// Delay before answering.
+	Latency time.Duration
+	// Close connection without answer.
+	Drop bool
+}
+
+// Rule injects the fault into the matching requests.
+type Rule struct {
+	// Request method. Matches any method if empty.
+	Method string
+	// Request path. Path ending with "*" matches by prefix.
+	Path string
+	// Percent of the matching requests to inject the fault into. Defaults to 100.
+	Percent float64
+	// Fault to inject.
+	Fault Fault
+}
+
+// Config for the middleware.
+type Config struct {
+	// Errors handler to send API errors through. Required.
+	Handler *handler.Handler
+	// Returns current environment. Defaults to the EnvGetter of the Handler.
+	EnvGetter func() string
+	// Environments to inject faults in, like "testing". Faults are off if empty
+	// and are never injected in "production".
+	Environments []string
+	// Header with the fault, like "error=not_found; latency=2s; drop". Defaults to "X-Fault-Inject".
+	Header string
+	// If set, faults from the header are injected only with this value in the X-Fault-Secret header.
+	Secret string
+	// Max latency to inject. Defaults to 30 seconds.
+	MaxLatency time.Duration
+	// Rules to inject faults without the header.
+	Rules []Rule
+	// Additional APIErrors to inject by their ids.
+	Errors []*apierr.APIError
+}
+
+// Injector middleware.
+type Injector struct {
+	Config
+
+	errors map[string]*apierr.APIError
+}
+
+// New middleware constructor.
+func New(config Config) *Injector {
+	if config.EnvGetter == nil {
+		config.EnvGetter = config.Handler.Config.EnvGetter
+	}
+	if config.Header == "" {
+		config.Header = defaultHeader
+	}
+	if config.MaxLatency <= 0 {
+		config.MaxLatency = defaultMaxLatency
+	}
+
+	known := []*apierr.APIError{
+		apierr.BadRequest,
+		apierr.NotFound,
+		apierr.ValiationFailed,
+		apierr.InternalServerError,
+		handler.MethodNotAllowed,
+		handler.TooManyRequests,
+		handler.BadGateway,
+	}
+
+	errors := make(map[string]*apierr.APIError)
+	for _, fail := range append(known, config.Errors...) {
+		errors[fail.Body.ID] = fail
+	}
+
+	return &Injector{
+		Config: config,
+		errors: errors,
+	}
+}
+
+// Serve the middleware.
+// Requests with faults are not counted in the error budget and lockout of the Handler.
+func (i *Injector) Serve(ctx iris.Context) {
+	fault := i.find(ctx.Request())
+	if fault == nil {
+		ctx.Next()
+		return
+	}
+
+	handler.Untrack(ctx)
+
+	if !i.wait(ctx.Request(), fault.Latency) {
+		return
+	}
+	if fault.Drop {
+		drop(ctx.ResponseWriter())
+		ctx.StopExecution()
+		return
+	}
+	if fail := i.failure(fault); fail != nil {
+		i.Handler.Inject(ctx, fail)
+		return
+	}
+
+	ctx.Next()
+}
+
+// Wrap makes net/http middleware with the same logic.
+func (i *Injector) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fault := i.find(r)
+		if fault == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		handler.UntrackHTTP(w)
+
+		if !i.wait(r, fault.Latency) {
+			return
+		}
+		if fault.Drop {
+			drop(w)
+			return
+		}
+		if fail := i.failure(fault); fail != nil {
+			i.Handler.InjectHTTP(w, r, fail)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Find fault for the request by header or rules.
+func (i *Injector) find(r *http.Request) *Fault {
+	if !i.isEnabled() {
+		return nil
+	}
+
+	if header := r.Header.Get(i.Header); header != "" && i.isSecret(r.Header.Get(secretHeader)) {
+		return parseFault(header)
+	}
+
+	for _, rule := range i.Rules {
+		if rule.matches(r) && chance(rule.Percent) {
+			fault := rule.Fault
+			return &fault
+		}
+	}
+
+	return nil
+}
+
+// Check if faults are allowed in the current environment.
+func (i *Injector) isEnabled() bool {
+	env := i.EnvGetter()
+	if env == "production" {
+		return false
+	}
+
+	for _, allowed := range i.Environments {
+		if allowed == env {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check the secret of the header faults.
+func (i *Injector) isSecret(secret string) bool {
+	if i.Secret == "" {
+		return true
+	}
+
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(i.Secret)) == 1
+}
+
+// Find APIError of the fault. Unknown ids are answered with bad_request.
+func (i *Injector) failure(fault *Fault) *apierr.APIError {
+	if fault.Error == "" {
+		return nil
+	}
+	if fail, ok := i.errors[fault.Error]; ok {
+		return fail
+	}
+
+	fail := *apierr.BadRequest
+	fail.Body.Message = fmt.Sprintf("Unknown fault error %q.", fault.Error)
+
+	return &fail
+}
+
+// Sleep for the latency capped by MaxLatency. Returns false if the client is gone.
+func (i *Injector) wait(r *http.Request, latency time.Duration) bool {
+	if latency <= 0 {
+		return true
+	}
+	if latency > i.MaxLatency {
+		latency = i.MaxLatency
+	}
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// Check if rule matches the request.
+func (rule *Rule) matches(r *http.Request) bool {
+	if rule.Method != "" && !strings.EqualFold(rule.Method, r.Method) {
+		return false
+	}
+
+	if strings.HasSuffix(rule.Path, "*") {
+		return strings.HasPrefix(r.URL.Path, strings.TrimSuffix(rule.Path, "*"))
+	}
+
+	return rule.Path == "" || rule.Path == r.URL.Path
+}
+
+// Roll the dice.
+func chance(percent float64) bool {
+	if percent <= 0 || percent >= 100 {
+		return true
+	}
+
+	return rand.Float64()*100 < percent
+}
+
+// Parse fault from the header, like "error=not_found; latency=2s; drop".
+func parseFault(header string) *Fault {
+	fault := &Fault{}
+
+	for _, part := range strings.Split(header, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "error":
+			fault.Error = value
+		case "latency":
+			fault.Latency, _ = time.ParseDuration(value)
+		case "drop":
+			fault.Drop = true
+		}
+	}
+
+	return fault
+}
+
+// Close the connection without answer.
+// If it can't be hijacked, the server is asked to abort the response.
+func drop(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+
+	conn.Close()
+}
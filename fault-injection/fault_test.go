@@ -0,0 +1,287 @@
+package fault_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	iristest "github.com/kataras/iris/v12/httptest"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+	fault "github.com/mlanin/iris-middlewares/fault-injection"
+)
+
+func TestItInjectsFaults(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+	injector := fault.New(fault.Config{
+		Handler:      errorsHandler.Handler,
+		EnvGetter:    func() string { return "testing" },
+		Environments: []string{"testing"},
+		Rules: []fault.Rule{
+			{Method: "GET", Path: "/slow/*", Fault: fault.Fault{Latency: 50 * time.Millisecond}},
+			{Path: "/broken", Fault: fault.Fault{Error: "bad_gateway"}},
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+	api.Use(injector.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		ctx.WriteString("Home")
+	})
+	api.Get("/broken", func(ctx iris.Context) {
+		ctx.WriteString("Works")
+	})
+	api.Get("/slow/{id}", func(ctx iris.Context) {
+		ctx.WriteString("Slow")
+	})
+
+	e := iristest.New(t, api)
+
+	e.GET("/").Expect().Status(iris.StatusOK).Body().Equal("Home")
+
+	resp := e.GET("/").WithHeader("X-Fault-Inject", "error=not_found").Expect()
+	apierrtest.AssertAPIError(t, resp, "not_found", iris.StatusNotFound)
+
+	apierrtest.AssertAPIError(t, e.GET("/broken").Expect(), "bad_gateway", iris.StatusBadGateway)
+
+	started := time.Now()
+	e.GET("/slow/1").Expect().Status(iris.StatusOK).Body().Equal("Slow")
+	if time.Since(started) < 50*time.Millisecond {
+		t.Error("Expected latency to be injected")
+	}
+}
+
+func TestItDoesNotInjectFaultsInProduction(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+	injector := fault.New(fault.Config{
+		Handler:      errorsHandler.Handler,
+		Environments: []string{"production", "staging"},
+		Rules: []fault.Rule{
+			{Path: "/", Fault: fault.Fault{Error: "internal_server_error"}},
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+	api.Use(injector.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		ctx.WriteString("Home")
+	})
+
+	e := iristest.New(t, api)
+
+	e.GET("/").WithHeader("X-Fault-Inject", "error=not_found").
+		Expect().
+		Status(iris.StatusOK).
+		Body().Equal("Home")
+}
+
+func TestItDoesNotInjectFaultsInUnlistedEnvironments(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+	injector := fault.New(fault.Config{
+		Handler:   errorsHandler.Handler,
+		EnvGetter: func() string { return "staging" },
+	})
+
+	api.Use(errorsHandler.Serve)
+	api.Use(injector.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		ctx.WriteString("Home")
+	})
+
+	e := iristest.New(t, api)
+
+	e.GET("/").WithHeader("X-Fault-Inject", "error=not_found").
+		Expect().
+		Status(iris.StatusOK).
+		Body().Equal("Home")
+}
+
+func TestItRequiresSecretForHeaderFaults(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+	injector := fault.New(fault.Config{
+		Handler:      errorsHandler.Handler,
+		EnvGetter:    func() string { return "testing" },
+		Environments: []string{"testing"},
+		Secret:       "s3cret",
+	})
+
+	api.Use(errorsHandler.Serve)
+	api.Use(injector.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		ctx.WriteString("Home")
+	})
+
+	e := iristest.New(t, api)
+
+	e.GET("/").WithHeader("X-Fault-Inject", "error=not_found").
+		Expect().
+		Status(iris.StatusOK)
+	e.GET("/").WithHeader("X-Fault-Inject", "error=not_found").WithHeader("X-Fault-Secret", "wrong").
+		Expect().
+		Status(iris.StatusOK)
+
+	resp := e.GET("/").WithHeader("X-Fault-Inject", "error=not_found").WithHeader("X-Fault-Secret", "s3cret").Expect()
+	apierrtest.AssertAPIError(t, resp, "not_found", iris.StatusNotFound)
+}
+
+func TestItDoesNotCountInjectedErrors(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{
+		EnvGetter: func() string { return "testing" },
+		Budget:    handler.BudgetConfig{Objective: 0.99},
+		Lockout:   handler.LockoutConfig{Threshold: 1},
+	})
+	injector := fault.New(fault.Config{
+		Handler:      errorsHandler.Handler,
+		Environments: []string{"testing"},
+	})
+
+	api.Use(errorsHandler.Serve)
+	api.Use(injector.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		ctx.WriteString("Home")
+	})
+
+	e := iristest.New(t, api)
+
+	resp := e.GET("/").WithHeader("X-Fault-Inject", "error=internal_server_error").Expect()
+	apierrtest.AssertAPIError(t, resp, "internal_server_error", iris.StatusInternalServerError)
+	resp.JSON().Object().NotContainsKey("meta")
+
+	e.GET("/").WithHeader("X-Fault-Inject", "error=validation_failed").Expect().Status(iris.StatusUnprocessableEntity)
+	e.GET("/").Expect().Status(iris.StatusOK)
+
+	if reports := errorsHandler.Reports(); len(reports) != 0 {
+		t.Error("Expected injected errors not to be reported, got", len(reports))
+	}
+	if routes := errorsHandler.Budget().Routes; len(routes) != 1 || routes[0].Requests != 1 || routes[0].Errors != 0 {
+		t.Error("Expected injected errors not to be counted in the budget, got", routes)
+	}
+}
+
+func TestItAnswersUnknownErrorWithBadRequest(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+	injector := fault.New(fault.Config{
+		Handler:      errorsHandler.Handler,
+		EnvGetter:    func() string { return "testing" },
+		Environments: []string{"testing"},
+	})
+
+	api.Use(errorsHandler.Serve)
+	api.Use(injector.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		ctx.WriteString("Home")
+	})
+
+	e := iristest.New(t, api)
+
+	resp := e.GET("/").WithHeader("X-Fault-Inject", "error=not_fuond").Expect()
+	apierrtest.AssertAPIError(t, resp, "bad_request", iris.StatusBadRequest)
+	resp.JSON().Object().Value("error").Object().Value("message").String().Contains("not_fuond")
+}
+
+func TestItCapsLatency(t *testing.T) {
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+	injector := fault.New(fault.Config{
+		Handler:      errorsHandler.Handler,
+		EnvGetter:    func() string { return "testing" },
+		Environments: []string{"testing"},
+		MaxLatency:   10 * time.Millisecond,
+	})
+
+	server := injector.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Home"))
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Fault-Inject", "latency=1h")
+
+	started := time.Now()
+	server.ServeHTTP(httptest.NewRecorder(), req)
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Error("Expected latency to be capped, took", elapsed)
+	}
+}
+
+func TestItDropsConnections(t *testing.T) {
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+	injector := fault.New(fault.Config{
+		Handler:      errorsHandler.Handler,
+		EnvGetter:    func() string { return "testing" },
+		Environments: []string{"testing"},
+	})
+
+	server := httptest.NewServer(errorsHandler.Wrap(injector.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Home"))
+	}))))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("X-Fault-Inject", "drop")
+
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatal("Expected connection to be dropped, got", resp.Status)
+	}
+}
+
+func TestItAbortsResponseIfConnectionCantBeDropped(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+	injector := fault.New(fault.Config{
+		Handler:      errorsHandler.Handler,
+		EnvGetter:    func() string { return "testing" },
+		Environments: []string{"testing"},
+	})
+
+	api.Use(errorsHandler.Serve)
+	api.Use(injector.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		ctx.WriteString("Home")
+	})
+
+	if err := api.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	servers := map[string]http.Handler{
+		"iris":     api,
+		"net/http": errorsHandler.Wrap(injector.Wrap(http.NotFoundHandler())),
+	}
+
+	for name, server := range servers {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Fault-Inject", "drop")
+
+		func() {
+			defer func() {
+				if err := recover(); err != http.ErrAbortHandler {
+					t.Error("Expected", name, "response to be aborted, got", err)
+				}
+			}()
+
+			server.ServeHTTP(httptest.NewRecorder(), req)
+		}()
+	}
+}
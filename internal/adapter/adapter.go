@@ -0,0 +1,46 @@
+// Package adapter hides the differences between Iris and net/http requests,
+// so the middlewares' logic doesn't depend on the framework.
+package adapter
+
+import (
+	"net/http"
+
+	"github.com/kataras/iris/v12"
+)
+
+// Context of the single request.
+type Context interface {
+	// Iris context or nil for net/http requests.
+	Iris() iris.Context
+	// Original request.
+	Request() *http.Request
+	// Path of the matched route.
+	Route() string
+	// ID of the request or empty string.
+	RequestID() string
+	// Path parameter by its name.
+	Param(name string) string
+	// All known path parameters.
+	Params() map[string]string
+	// Session of the request or nil if sessions are not used.
+	Session() Session
+	// Log writes message to the log.
+	Log(message string)
+	// JSON sends v with the status.
+	JSON(status int, v interface{})
+	// HTML sends markup with the status.
+	HTML(status int, markup string)
+	// Redirect to the url.
+	Redirect(url string, status int)
+}
+
+// Header with the request ID.
+const requestIDHeader = "X-Request-Id"
+
+// Session storage.
+type Session interface {
+	Set(key string, value interface{})
+	SetFlash(key string, value interface{})
+	GetFlash(key string) interface{}
+	GetAll() map[string]interface{}
+}
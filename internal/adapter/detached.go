@@ -0,0 +1,65 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kataras/iris/v12"
+)
+
+// Context of the work that outlives its request, like goroutines.
+// It can't respond, so rendering is ignored.
+type detachedContext struct {
+	r         *http.Request
+	route     string
+	requestID string
+	log       func(message string)
+}
+
+// Detach copies details of the request, so they can be used after it is done.
+func Detach(c Context, log func(message string)) Context {
+	return &detachedContext{
+		r:         c.Request().Clone(context.Background()),
+		route:     c.Route(),
+		requestID: c.RequestID(),
+		log:       log,
+	}
+}
+
+func (c *detachedContext) Iris() iris.Context {
+	return nil
+}
+
+func (c *detachedContext) Request() *http.Request {
+	return c.r
+}
+
+func (c *detachedContext) Route() string {
+	return c.route
+}
+
+func (c *detachedContext) RequestID() string {
+	return c.requestID
+}
+
+func (c *detachedContext) Param(name string) string {
+	return ""
+}
+
+func (c *detachedContext) Params() map[string]string {
+	return nil
+}
+
+func (c *detachedContext) Session() Session {
+	return nil
+}
+
+func (c *detachedContext) Log(message string) {
+	c.log(message)
+}
+
+func (c *detachedContext) JSON(status int, v interface{}) {}
+
+func (c *detachedContext) HTML(status int, markup string) {}
+
+func (c *detachedContext) Redirect(url string, status int) {}
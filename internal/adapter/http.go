@@ -0,0 +1,70 @@
+package adapter
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/kataras/iris/v12"
+)
+
+// Context of the net/http request.
+type httpContext struct {
+	w      http.ResponseWriter
+	r      *http.Request
+	logger *log.Logger
+}
+
+// FromHTTP adapts net/http request.
+func FromHTTP(w http.ResponseWriter, r *http.Request, logger *log.Logger) Context {
+	return &httpContext{w: w, r: r, logger: logger}
+}
+
+func (c *httpContext) Iris() iris.Context {
+	return nil
+}
+
+func (c *httpContext) Request() *http.Request {
+	return c.r
+}
+
+func (c *httpContext) Route() string {
+	return c.r.Pattern
+}
+
+func (c *httpContext) RequestID() string {
+	return c.r.Header.Get(requestIDHeader)
+}
+
+func (c *httpContext) Param(name string) string {
+	return c.r.PathValue(name)
+}
+
+func (c *httpContext) Params() map[string]string {
+	// net/http can't list path values.
+	return nil
+}
+
+func (c *httpContext) Session() Session {
+	return nil
+}
+
+func (c *httpContext) Log(message string) {
+	c.logger.Println(message)
+}
+
+func (c *httpContext) JSON(status int, v interface{}) {
+	c.w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	c.w.WriteHeader(status)
+	json.NewEncoder(c.w).Encode(v)
+}
+
+func (c *httpContext) HTML(status int, markup string) {
+	c.w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	c.w.WriteHeader(status)
+	c.w.Write([]byte(markup))
+}
+
+func (c *httpContext) Redirect(url string, status int) {
+	http.Redirect(c.w, c.r, url, status)
+}
@@ -0,0 +1,85 @@
+package adapter
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/sessions"
+)
+
+// Context of the Iris request.
+type irisContext struct {
+	ctx iris.Context
+}
+
+// FromIris adapts Iris context.
+func FromIris(ctx iris.Context) Context {
+	return &irisContext{ctx: ctx}
+}
+
+func (c *irisContext) Iris() iris.Context {
+	return c.ctx
+}
+
+func (c *irisContext) Request() *http.Request {
+	return c.ctx.Request()
+}
+
+func (c *irisContext) Route() string {
+	if route := c.ctx.GetCurrentRoute(); route != nil {
+		return route.Path()
+	}
+
+	return ""
+}
+
+func (c *irisContext) RequestID() string {
+	if id := c.ctx.GetID(); id != nil {
+		return fmt.Sprint(id)
+	}
+
+	return c.ctx.GetHeader(requestIDHeader)
+}
+
+func (c *irisContext) Param(name string) string {
+	return c.ctx.Params().Get(name)
+}
+
+func (c *irisContext) Params() map[string]string {
+	params := make(map[string]string)
+	c.ctx.Params().Visit(func(key string, value string) {
+		params[key] = value
+	})
+
+	return params
+}
+
+func (c *irisContext) Session() Session {
+	// Don't return typed nil when sessions middleware is not registered.
+	session := sessions.Get(c.ctx)
+	if session == nil {
+		return nil
+	}
+
+	return session
+}
+
+func (c *irisContext) Log(message string) {
+	c.ctx.Application().Logger().Error(message)
+}
+
+func (c *irisContext) JSON(status int, v interface{}) {
+	c.ctx.StatusCode(status)
+	c.ctx.JSON(v)
+}
+
+func (c *irisContext) HTML(status int, markup string) {
+	c.ctx.ContentType("text/html; charset=UTF-8")
+	c.ctx.StatusCode(status)
+	c.ctx.WriteString(markup)
+}
+
+func (c *irisContext) Redirect(url string, status int) {
+	c.ctx.Redirect(url, status)
+}
@@ -0,0 +1,135 @@
+// Package clientip resolves the real client IP behind trusted proxies.
+package clientip
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseNetworks parses IPs and CIDRs skipping invalid ones.
+func ParseNetworks(addresses []string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(addresses))
+
+	for _, address := range addresses {
+		if !strings.Contains(address, "/") {
+			if strings.Contains(address, ":") {
+				address += "/128"
+			} else {
+				address += "/32"
+			}
+		}
+
+		if _, network, err := net.ParseCIDR(address); err == nil {
+			networks = append(networks, network)
+		}
+	}
+
+	return networks
+}
+
+// Contains checks if IP belongs to any of the networks.
+func Contains(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolve client IP of the request. Forwarded, X-Forwarded-For and X-Real-IP headers
+// are used only when the immediate peer is one of the trusted proxies.
+func Resolve(r *http.Request, trusted []*net.IPNet) string {
+	peer := Peer(r)
+	if !Contains(trusted, net.ParseIP(peer)) {
+		return peer
+	}
+
+	chain := forwarded(r.Header.Values("Forwarded"))
+	if len(chain) == 0 {
+		chain = list(r.Header.Values("X-Forwarded-For"))
+	}
+
+	// Go from the closest hop and stop at the first untrusted one.
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := net.ParseIP(chain[i])
+		if ip == nil {
+			break
+		}
+		if i == 0 || !Contains(trusted, ip) {
+			return ip.String()
+		}
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+
+	return peer
+}
+
+// Peer returns IP of the immediate peer.
+func Peer(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// Parse comma separated addresses.
+func list(values []string) []string {
+	addresses := make([]string, 0)
+
+	for _, value := range values {
+		for _, address := range strings.Split(value, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+
+	return addresses
+}
+
+// Parse "for" addresses of RFC 7239 Forwarded header.
+func forwarded(values []string) []string {
+	addresses := make([]string, 0)
+
+	for _, element := range list(values) {
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || !strings.EqualFold(key, "for") {
+				continue
+			}
+
+			addresses = append(addresses, forwardedNode(value))
+		}
+	}
+
+	return addresses
+}
+
+// Strip quotes, brackets and port from the node, like "[2001:db8::1]:4711".
+func forwardedNode(node string) string {
+	node = strings.Trim(node, `"`)
+
+	if strings.HasPrefix(node, "[") {
+		if end := strings.Index(node, "]"); end > 0 {
+			return node[1:end]
+		}
+	}
+
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+
+	return node
+}
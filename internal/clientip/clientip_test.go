@@ -0,0 +1,44 @@
+package clientip_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mlanin/iris-middlewares/internal/clientip"
+)
+
+type ipcase struct {
+	remote string
+	header string
+	value  string
+	result string
+}
+
+var resolveTests = []ipcase{
+	{"1.2.3.4:80", "X-Forwarded-For", "5.6.7.8", "1.2.3.4"},
+	{"10.0.0.1:80", "X-Forwarded-For", "5.6.7.8", "5.6.7.8"},
+	{"10.0.0.1:80", "X-Forwarded-For", "5.6.7.8, 9.9.9.9, 10.0.0.2", "9.9.9.9"},
+	{"10.0.0.1:80", "X-Forwarded-For", "10.0.0.3, 10.0.0.2", "10.0.0.3"},
+	{"10.0.0.1:80", "X-Real-IP", "5.6.7.8", "5.6.7.8"},
+	{"10.0.0.1:80", "Forwarded", `for=192.0.2.60;proto=http;by=203.0.113.43`, "192.0.2.60"},
+	{"10.0.0.1:80", "Forwarded", `for="[2001:db8:cafe::17]:4711"`, "2001:db8:cafe::17"},
+	{"10.0.0.1:80", "Forwarded", `for=unknown`, "10.0.0.1"},
+}
+
+func TestResolve(t *testing.T) {
+	trusted := clientip.ParseNetworks([]string{"10.0.0.0/8"})
+
+	for _, c := range resolveTests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = c.remote
+		r.Header.Set(c.header, c.value)
+
+		if ip := clientip.Resolve(r, trusted); ip != c.result {
+			t.Error(
+				"For", c.header, c.value,
+				"expected", c.result,
+				"got", ip,
+			)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package maintenance
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/internal/clientip"
+)
+
+const (
+	defaultRetryAfter = time.Minute
+	defaultCookie     = "maintenance_bypass"
+	defaultHeader     = "X-Maintenance-Bypass"
+	defaultPage       = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Be right back</title></head>
+<body><h1>Be right back.</h1><p>We are doing some maintenance. Please try again in a few minutes.</p></body>
+</html>`
+)
+
+// ServiceUnavailable is sent while maintenance mode is on.
+var ServiceUnavailable = &apierr.APIError{
+	Body: apierr.Body{
+		ID:      "service_unavailable",
+		Message: "Service is under maintenance.",
+	},
+	HTTPCode: http.StatusServiceUnavailable,
+}
+
+// Config for the middleware.
+type Config struct {
+	// Errors handler to send API errors through. Required.
+	Handler *handler.Handler
+	// Tells if maintenance mode is on.
+	MaintenanceGetter func() bool
+	// Maintenance mode is on while this file exists.
+	MarkerFile string
+	// Value of the Retry-After header. Defaults to 1 minute.
+	RetryAfter time.Duration
+	// Secret to bypass maintenance mode with cookie or header.
+	Secret string
+	// Cookie with the secret. Defaults to "maintenance_bypass".
+	Cookie string
+	// Header with the secret. Defaults to "X-Maintenance-Bypass".
+	Header string
+	// Client IPs or CIDRs allowed during maintenance.
+	AllowedIPs []string
+	// IPs or CIDRs of the proxies trusted to pass client IP in headers.
+	TrustedProxies []string
+	// Paths served during maintenance. Path ending with "*" matches by prefix.
+	Paths []string
+	// HTML page for browsers.
+	Page string
+}
+
+// Maintenance middleware.
+type Maintenance struct {
+	Config
+
+	networks       []*net.IPNet
+	trustedProxies []*net.IPNet
+}
+
+// New middleware constructor.
+func New(config Config) *Maintenance {
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = defaultRetryAfter
+	}
+	if config.Cookie == "" {
+		config.Cookie = defaultCookie
+	}
+	if config.Header == "" {
+		config.Header = defaultHeader
+	}
+	if config.Page == "" {
+		config.Page = defaultPage
+	}
+
+	return &Maintenance{
+		Config:         config,
+		networks:       clientip.ParseNetworks(config.AllowedIPs),
+		trustedProxies: clientip.ParseNetworks(config.TrustedProxies),
+	}
+}
+
+// Serve the middleware.
+func (m *Maintenance) Serve(ctx iris.Context) {
+	r := ctx.Request()
+	if !m.isDown() || m.canBypass(r) {
+		ctx.Next()
+		return
+	}
+
+	ctx.Header("Retry-After", m.retryAfter())
+
+	if m.wantsHTML(r) {
+		ctx.ContentType("text/html; charset=UTF-8")
+		ctx.StatusCode(http.StatusServiceUnavailable)
+		ctx.WriteString(m.Page)
+		return
+	}
+
+	m.Handler.Handle(ctx, ServiceUnavailable)
+}
+
+// Wrap makes net/http middleware with the same logic.
+func (m *Maintenance) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.isDown() || m.canBypass(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", m.retryAfter())
+
+		if m.wantsHTML(r) {
+			w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(m.Page))
+			return
+		}
+
+		m.Handler.HandleHTTP(w, r, ServiceUnavailable)
+	})
+}
+
+// Check if maintenance mode is on.
+func (m *Maintenance) isDown() bool {
+	if m.MaintenanceGetter != nil && m.MaintenanceGetter() {
+		return true
+	}
+
+	if m.MarkerFile != "" {
+		if _, err := os.Stat(m.MarkerFile); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if request can be served during maintenance.
+func (m *Maintenance) canBypass(r *http.Request) bool {
+	return m.isWhitelistedPath(r.URL.Path) || m.hasSecret(r) || m.isAllowedIP(r)
+}
+
+// Check if path is whitelisted.
+func (m *Maintenance) isWhitelistedPath(path string) bool {
+	for _, allowed := range m.Paths {
+		if strings.HasSuffix(allowed, "*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(allowed, "*")) {
+				return true
+			}
+		} else if path == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if request has bypass secret in header or cookie.
+func (m *Maintenance) hasSecret(r *http.Request) bool {
+	if m.Secret == "" {
+		return false
+	}
+
+	if m.isSecret(r.Header.Get(m.Header)) {
+		return true
+	}
+
+	cookie, err := r.Cookie(m.Cookie)
+
+	return err == nil && m.isSecret(cookie.Value)
+}
+
+// Compare value with the secret in constant time.
+func (m *Maintenance) isSecret(value string) bool {
+	return subtle.ConstantTimeCompare([]byte(value), []byte(m.Secret)) == 1
+}
+
+// Check if client IP is allowed.
+func (m *Maintenance) isAllowedIP(r *http.Request) bool {
+	return clientip.Contains(m.networks, net.ParseIP(clientip.Resolve(r, m.trustedProxies)))
+}
+
+// If request was made by browser.
+func (m *Maintenance) wantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// Retry-After header value in seconds.
+func (m *Maintenance) retryAfter() string {
+	return strconv.Itoa(int(m.RetryAfter.Seconds()))
+}
@@ -0,0 +1,96 @@
+package maintenance_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kataras/iris/v12"
+	iristest "github.com/kataras/iris/v12/httptest"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+	maintenance "github.com/mlanin/iris-middlewares/maintenance-mode"
+)
+
+func TestItAnswersWithServiceUnavailable(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+	down := maintenance.New(maintenance.Config{
+		Handler: errorsHandler.Handler,
+		MaintenanceGetter: func() bool {
+			return true
+		},
+		Secret: "secret",
+		Paths:  []string{"/health"},
+	})
+
+	api.Use(errorsHandler.Serve)
+	api.Use(down.Serve)
+
+	api.Get("/", func(ctx iris.Context) {
+		ctx.WriteString("Home")
+	})
+	api.Get("/health", func(ctx iris.Context) {
+		ctx.WriteString("OK")
+	})
+
+	e := iristest.New(t, api)
+
+	resp := e.GET("/").Expect()
+	apierrtest.AssertAPIError(t, resp, "service_unavailable", iris.StatusServiceUnavailable)
+	resp.Header("Retry-After").Equal("60")
+
+	e.GET("/").WithHeader("Accept", "text/html").
+		Expect().
+		Status(iris.StatusServiceUnavailable).
+		ContentType("text/html")
+
+	e.GET("/").WithHeader("X-Maintenance-Bypass", "secret").
+		Expect().
+		Status(iris.StatusOK).
+		Body().Equal("Home")
+
+	e.GET("/health").
+		Expect().
+		Status(iris.StatusOK).
+		Body().Equal("OK")
+}
+
+func TestItLetsAllowedIPsThrough(t *testing.T) {
+	errorsHandler := handler.New(handler.Config{
+		EnvGetter: func() string {
+			return "production"
+		},
+		DebugGetter: func() bool {
+			return false
+		},
+	})
+	down := maintenance.New(maintenance.Config{
+		Handler: errorsHandler,
+		MaintenanceGetter: func() bool {
+			return true
+		},
+		AllowedIPs: []string{"10.0.0.0/8"},
+	})
+
+	server := errorsHandler.Wrap(down.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Home"))
+	})))
+
+	allowed := httptest.NewRequest("GET", "/", nil)
+	allowed.RemoteAddr = "10.1.2.3:1234"
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, allowed)
+	if w.Code != http.StatusOK {
+		t.Error("Expected 200 for allowed IP, got", w.Code)
+	}
+
+	denied := httptest.NewRequest("GET", "/", nil)
+	denied.RemoteAddr = "192.168.1.1:1234"
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, denied)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Error("Expected 503 for other IP, got", w.Code)
+	}
+}
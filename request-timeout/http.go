@@ -0,0 +1,119 @@
+package timeout
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Wrap makes net/http middleware with the same logic.
+// Handler runs in its own goroutine, so the error is sent right at the deadline.
+func (t *Timeout) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeKey(r.Method, r.Pattern)
+
+		timeout := t.timeoutFor(route)
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		deadline, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		r = r.WithContext(deadline)
+		tw := &timeoutWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panics := make(chan interface{}, 1)
+		started := time.Now()
+
+		go func() {
+			defer func() {
+				if err := recover(); err != nil {
+					panics <- err
+				}
+			}()
+
+			next.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case err := <-panics:
+			// Let the errors handler recover it.
+			panic(err)
+		case <-done:
+			tw.flush(w)
+		case <-deadline.Done():
+			tw.discard()
+
+			if deadline.Err() == context.DeadlineExceeded {
+				t.Handler.HandleHTTP(w, r, t.timedOut(route, time.Since(started)))
+			}
+		}
+	})
+}
+
+// Buffers response until the handler is done.
+type timeoutWriter struct {
+	mu        sync.Mutex
+	header    http.Header
+	body      bytes.Buffer
+	code      int
+	discarded bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(data []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.discarded {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+
+	return tw.body.Write(data)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.discarded || tw.code != 0 {
+		return
+	}
+
+	tw.code = code
+}
+
+// Ignore all further writes.
+func (tw *timeoutWriter) discard() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.discarded = true
+}
+
+// Send buffered response.
+func (tw *timeoutWriter) flush(w http.ResponseWriter) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	for key, values := range tw.header {
+		w.Header()[key] = values
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+
+	w.WriteHeader(tw.code)
+	w.Write(tw.body.Bytes())
+}
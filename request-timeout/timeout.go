@@ -0,0 +1,121 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/mlanin/go-apierr"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+)
+
+// Default request timeout.
+const defaultTimeout = 30 * time.Second
+
+// GatewayTimeout is sent when request exceeds its deadline.
+var GatewayTimeout = &apierr.APIError{
+	Body: apierr.Body{
+		ID:      "gateway_timeout",
+		Message: "Request timed out.",
+	},
+	HTTPCode:     http.StatusGatewayTimeout,
+	ShouldReport: true,
+}
+
+// Config for the middleware.
+type Config struct {
+	// Errors handler to send timeout errors through. Required.
+	Handler *handler.Handler
+	// Default request timeout. Defaults to 30 seconds, negative turns it off.
+	Timeout time.Duration
+	// Timeouts for the routes by their method and path, like "GET /news/{id}".
+	// Zero turns timeout off for the route, so streaming responses are not buffered.
+	Routes map[string]time.Duration
+}
+
+// Timeout middleware.
+type Timeout struct {
+	Config
+}
+
+// Details of the timed out request.
+type timeoutContext struct {
+	Route   string `json:"route"`
+	Elapsed string `json:"elapsed"`
+}
+
+// New middleware constructor.
+func New(config Config) *Timeout {
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	return &Timeout{config}
+}
+
+// Serve the middleware.
+//
+// Iris contexts are pooled and can't outlive the handlers chain, so handlers run in the
+// request goroutine. Handlers that respect the request context are cancelled on time,
+// writes of the others are discarded and the error is sent when they return.
+func (t *Timeout) Serve(ctx iris.Context) {
+	route := ""
+	if current := ctx.GetCurrentRoute(); current != nil {
+		route = routeKey(ctx.Method(), current.Path())
+	}
+
+	timeout := t.timeoutFor(route)
+	if timeout <= 0 {
+		ctx.Next()
+		return
+	}
+
+	deadline, cancel := context.WithTimeout(ctx.Request().Context(), timeout)
+	defer cancel()
+
+	ctx.ResetRequest(ctx.Request().WithContext(deadline))
+
+	// Buffer response to be able to discard it.
+	ctx.Record()
+
+	started := time.Now()
+	ctx.Next()
+
+	if deadline.Err() != context.DeadlineExceeded {
+		return
+	}
+
+	ctx.Recorder().Reset()
+	t.Handler.Handle(ctx, t.timedOut(route, time.Since(started)))
+}
+
+// Get timeout for the route. Zero or negative means no timeout.
+func (t *Timeout) timeoutFor(route string) time.Duration {
+	if timeout, ok := t.Routes[route]; ok {
+		return timeout
+	}
+
+	return t.Timeout
+}
+
+// Make route key with the method, like "GET /news/{id}".
+func routeKey(method string, pattern string) string {
+	if pattern == "" || strings.Contains(pattern, " ") {
+		return pattern
+	}
+
+	return method + " " + pattern
+}
+
+// Make timeout error with request details.
+func (t *Timeout) timedOut(route string, elapsed time.Duration) *apierr.APIError {
+	fail := *GatewayTimeout
+	fail.AddContext(&timeoutContext{
+		Route:   route,
+		Elapsed: elapsed.String(),
+	})
+
+	return &fail
+}
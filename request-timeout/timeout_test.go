@@ -0,0 +1,88 @@
+package timeout_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	iristest "github.com/kataras/iris/v12/httptest"
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
+	timeout "github.com/mlanin/iris-middlewares/request-timeout"
+)
+
+func TestItCancelsSlowIrisRequest(t *testing.T) {
+	api := iris.New()
+
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+	timeouts := timeout.New(timeout.Config{
+		Handler: errorsHandler.Handler,
+		Timeout: time.Second,
+		Routes: map[string]time.Duration{
+			"GET /slow":  10 * time.Millisecond,
+			"GET /event": 0,
+		},
+	})
+
+	api.Use(errorsHandler.Serve)
+	api.Use(timeouts.Serve)
+
+	api.Get("/slow", func(ctx iris.Context) {
+		select {
+		case <-ctx.Request().Context().Done():
+			ctx.WriteString("Cancelled")
+		case <-time.After(time.Second):
+			ctx.WriteString("Done")
+		}
+	})
+	api.Get("/fast", func(ctx iris.Context) {
+		ctx.WriteString("Done")
+	})
+	api.Get("/event", func(ctx iris.Context) {
+		if _, recording := ctx.IsRecording(); recording {
+			ctx.WriteString("Buffered")
+			return
+		}
+
+		ctx.WriteString("Streamed")
+	})
+
+	e := iristest.New(t, api)
+	apierrtest.AssertAPIError(t, e.GET("/slow").Expect(), "gateway_timeout", iris.StatusGatewayTimeout)
+	e.GET("/fast").Expect().Status(iris.StatusOK).Body().Equal("Done")
+	e.GET("/event").Expect().Status(iris.StatusOK).Body().Equal("Streamed")
+
+	if len(errorsHandler.Reports()) != 1 {
+		t.Error("Expected timeout to be reported")
+	}
+}
+
+func TestItDiscardsLateNetHTTPWrites(t *testing.T) {
+	errorsHandler := handler.New(handler.Config{
+		EnvGetter: func() string {
+			return "production"
+		},
+		DebugGetter: func() bool {
+			return false
+		},
+	})
+	timeouts := timeout.New(timeout.Config{
+		Handler: errorsHandler,
+		Timeout: 10 * time.Millisecond,
+	})
+
+	server := errorsHandler.Wrap(timeouts.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ignores the request context.
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("Late"))
+	})))
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Error("Expected 504, got", w.Code)
+	}
+}
@@ -44,3 +44,12 @@ func NewContext(request HTTPRequest) *Context {
 
 	return context
 }
+
+// Make new context with empty request of the same type.
+func (c *Context) fresh() *Context {
+	return &Context{
+		Request: reflect.New(reflect.TypeOf(c.Request).Elem()).Interface().(HTTPRequest),
+		Name:    c.Name,
+		Type:    c.Type,
+	}
+}
@@ -0,0 +1,57 @@
+package validator
+
+import (
+	stdcontext "context"
+	"net/http"
+
+	"github.com/mlanin/iris-middlewares/internal/adapter"
+)
+
+// Key to store validated request in the request context.
+type contextKey string
+
+// ValidateHTTPRequest makes net/http middleware that validates request.
+// Valid request is stored in the request context, use Validated to get it.
+func (rv *RequestsValidator) ValidateHTTPRequest(request HTTPRequest) func(http.Handler) http.Handler {
+	template := NewContext(request)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Every request gets its own instance.
+			context := template.fresh()
+
+			context.Errors = rv.populateRequest(context, adapter.FromHTTP(w, r, nil))
+			if context.Errors != nil {
+				rv.HTTPBadRequestHandler(context, w, r)
+				return
+			}
+
+			context.Errors = context.Request.Validate()
+
+			if context.Errors == nil {
+				next.ServeHTTP(w, r.WithContext(stdcontext.WithValue(r.Context(), contextKey(context.Name), context.Request)))
+				return
+			}
+
+			rv.recordSpan(context, r)
+			rv.HTTPHandler(context, w, r)
+		})
+	}
+}
+
+// Validated returns request stored by ValidateHTTPRequest under its full name.
+func Validated(r *http.Request, name string) HTTPRequest {
+	request, _ := r.Context().Value(contextKey(name)).(HTTPRequest)
+
+	return request
+}
+
+// Send API validation error to be handled by the errors handler.
+func (rv *RequestsValidator) sendHTTPError(context *Context, w http.ResponseWriter, r *http.Request) {
+	panic(rv.validationFailed(context))
+}
+
+// Default bad request callback for net/http requests.
+func (rv *RequestsValidator) sendHTTPBadRequest(context *Context, w http.ResponseWriter, r *http.Request) {
+	panic(context.Errors)
+}
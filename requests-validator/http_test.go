@@ -0,0 +1,65 @@
+package validator_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	validator "github.com/mlanin/iris-middlewares/requests-validator"
+)
+
+func newHTTPServer() http.Handler {
+	rv := validator.New(validator.Config{})
+	errorsHandler := handler.New(handler.Config{
+		EnvGetter: func() string {
+			return "production"
+		},
+		DebugGetter: func() bool {
+			return false
+		},
+	})
+
+	validate := rv.ValidateHTTPRequest(&PostNewsJSON{})
+
+	return errorsHandler.Wrap(validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := validator.Validated(r, "validator_test.PostNewsJSON").(*PostNewsJSON)
+
+		w.Write([]byte(request.Text))
+	})))
+}
+
+func TestItPassesNetHTTPRequest(t *testing.T) {
+	w := httptest.NewRecorder()
+	newHTTPServer().ServeHTTP(w, httptest.NewRequest("POST", "/news", strings.NewReader(`{"text":"Foo bar"}`)))
+
+	if w.Code != http.StatusOK || w.Body.String() != "Foo bar" {
+		t.Error("Unexpected response", w.Code, w.Body.String())
+	}
+}
+
+func TestItFailsNetHTTPRequest(t *testing.T) {
+	w := httptest.NewRecorder()
+	newHTTPServer().ServeHTTP(w, httptest.NewRequest("POST", "/news", strings.NewReader(`{"foo":123}`)))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatal("Expected 422, got", w.Code)
+	}
+
+	var body struct {
+		Meta struct {
+			Errors []struct {
+				Field   string `json:"field"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"meta"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Meta.Errors) != 1 || body.Meta.Errors[0].Field != "text" {
+		t.Error("Unexpected errors", body.Meta.Errors)
+	}
+}
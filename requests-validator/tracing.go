@@ -0,0 +1,27 @@
+package validator
+
+import (
+	"net/http"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Add event with the number of failed fields to the active OpenTelemetry span.
+func (rv *RequestsValidator) recordSpan(context *Context, r *http.Request) {
+	span := trace.SpanFromContext(r.Context())
+	if !span.IsRecording() {
+		return
+	}
+
+	failed := 1
+	if fails, ok := context.Errors.(validation.Errors); ok {
+		failed = len(fails)
+	}
+
+	span.AddEvent("validation.failed", trace.WithAttributes(
+		attribute.String("validation.request", context.Name),
+		attribute.Int("validation.failed_fields", failed),
+	))
+}
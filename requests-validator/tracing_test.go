@@ -0,0 +1,45 @@
+package validator_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	validator "github.com/mlanin/iris-middlewares/requests-validator"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestItAddsValidationEventToSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	rv := validator.New(validator.Config{
+		HTTPHandler: func(context *validator.Context, w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		},
+	})
+	validate := rv.ValidateHTTPRequest(&PostNewsJSON{})(http.NotFoundHandler())
+
+	r := httptest.NewRequest("POST", "/news", strings.NewReader(`{"foo":123}`))
+	spanCtx, span := tracer.Start(r.Context(), "request")
+	validate.ServeHTTP(httptest.NewRecorder(), r.WithContext(spanCtx))
+	span.End()
+
+	events := recorder.Ended()[0].Events()
+	if len(events) != 1 || events[0].Name != "validation.failed" {
+		t.Fatal("Expected validation.failed event, got", events)
+	}
+
+	found := false
+	for _, attr := range events[0].Attributes {
+		if attr == attribute.Int("validation.failed_fields", 1) {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected failed fields attribute, got", events[0].Attributes)
+	}
+}
@@ -1,14 +1,19 @@
 package validator
 
 import (
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"net/http"
 	"reflect"
 	"strconv"
 	"strings"
 
 	validation "github.com/go-ozzo/ozzo-validation"
-	"github.com/kataras/iris"
+	"github.com/iris-contrib/schema"
+	"github.com/kataras/iris/v12"
 	"github.com/mlanin/go-apierr"
+	"github.com/mlanin/iris-middlewares/internal/adapter"
 )
 
 const (
@@ -28,11 +33,15 @@ type HTTPRequest interface {
 // Config for the middleware.
 type Config struct {
 	// Handle error for common http request.
-	WebHandler func(context *Context, ctx *iris.Context)
+	WebHandler func(context *Context, ctx iris.Context)
 	// Handle error for API calls.
-	APIHandler func(context *Context, ctx *iris.Context)
+	APIHandler func(context *Context, ctx iris.Context)
 	// Handle unmarshal request error.
-	BadRequestHandler func(context *Context, ctx *iris.Context)
+	BadRequestHandler func(context *Context, ctx iris.Context)
+	// Handle error for net/http requests.
+	HTTPHandler func(context *Context, w http.ResponseWriter, r *http.Request)
+	// Handle unmarshal request error for net/http requests.
+	HTTPBadRequestHandler func(context *Context, w http.ResponseWriter, r *http.Request)
 }
 
 // RequestsValidator for http requests.
@@ -55,41 +64,53 @@ func New(config Config) *RequestsValidator {
 	if validator.BadRequestHandler == nil {
 		validator.BadRequestHandler = validator.sendBadRequest
 	}
+	if validator.HTTPHandler == nil {
+		validator.HTTPHandler = validator.sendHTTPError
+	}
+	if validator.HTTPBadRequestHandler == nil {
+		validator.HTTPBadRequestHandler = validator.sendHTTPBadRequest
+	}
 
 	return validator
 }
 
 // Serve the middleware.
-func (rv *RequestsValidator) Serve(ctx *iris.Context) {
+func (rv *RequestsValidator) Serve(ctx iris.Context) {
 	ctx.Next()
 
 	// Save current url.
-	rv.storeCurrentURL(ctx)
+	rv.storeCurrentURL(adapter.FromIris(ctx))
 }
 
 // ValidateRequest helper function to make validator.
-func (rv *RequestsValidator) ValidateRequest(request HTTPRequest) iris.HandlerFunc {
-	context := NewContext(request)
+func (rv *RequestsValidator) ValidateRequest(request HTTPRequest) iris.Handler {
+	template := NewContext(request)
+
+	return func(ctx iris.Context) {
+		// Every request gets its own instance.
+		context := template.fresh()
 
-	return func(ctx *iris.Context) {
-		context.Errors = rv.populateRequest(context, ctx)
+		context.Errors = rv.populateRequest(context, adapter.FromIris(ctx))
 		if context.Errors != nil {
 			rv.BadRequestHandler(context, ctx)
+			return
 		}
 
 		context.Errors = context.Request.Validate()
 
 		if context.Errors == nil {
 			// Save request to use it futher in controller.
-			ctx.Set(context.Name, context.Request)
+			ctx.Values().Set(context.Name, context.Request)
 
 			// Switch to next handler.
 			ctx.Next()
 			return
 		}
 
+		rv.recordSpan(context, ctx.Request())
+
 		// Convert errors to validation fails and send them to the user.
-		if rv.wantsJSON(ctx) {
+		if rv.wantsJSON(ctx.Request()) {
 			rv.APIHandler(context, ctx)
 		} else {
 			rv.WebHandler(context, ctx)
@@ -98,35 +119,43 @@ func (rv *RequestsValidator) ValidateRequest(request HTTPRequest) iris.HandlerFu
 }
 
 // Populate Request with data.
-func (rv *RequestsValidator) populateRequest(context *Context, ctx *iris.Context) error {
+func (rv *RequestsValidator) populateRequest(context *Context, c adapter.Context) error {
+	r := c.Request()
+
 	switch context.Type {
 	case jsonRequest:
-		return ctx.ReadJSON(&context.Request)
+		return json.NewDecoder(r.Body).Decode(context.Request)
 	case xmlRequest:
-		return ctx.ReadXML(&context.Request)
+		return xml.NewDecoder(r.Body).Decode(context.Request)
 	case formRequest:
-		return ctx.ReadForm(&context.Request)
+		return rv.populateFromForm(context, c)
 	case queryRequest:
-		return rv.populateFromQuery(context.Request, ctx)
+		return rv.populateFromSource(context.Request, queryTag, r.URL.Query().Get)
 	case paramsRequest:
-		return rv.populateFromParams(context.Request, ctx)
+		return rv.populateFromSource(context.Request, queryTag, c.Param)
 	}
 
 	return nil
 }
 
-// Populate requst from query.
-func (rv *RequestsValidator) populateFromQuery(request HTTPRequest, ctx *iris.Context) error {
-	return rv.populateFromSource(request, ctx.URLParam)
-}
+// Populate Request from the form.
+// Fields of any type the form decoder knows are supported: slices, nested structs, floats, etc.
+func (rv *RequestsValidator) populateFromForm(context *Context, c adapter.Context) error {
+	if ctx := c.Iris(); ctx != nil {
+		return ctx.ReadForm(context.Request)
+	}
+
+	r := c.Request()
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
 
-// Populate request from URL params.
-func (rv *RequestsValidator) populateFromParams(request HTTPRequest, ctx *iris.Context) error {
-	return rv.populateFromSource(request, ctx.Param)
+	return schema.DecodeForm(r.Form, context.Request)
 }
 
 // Populate Request with data from custom source.
-func (rv *RequestsValidator) populateFromSource(request HTTPRequest, source func(key string) string) error {
+// Fields are searched by the given tag or by their names.
+func (rv *RequestsValidator) populateFromSource(request HTTPRequest, tagName string, source func(key string) string) error {
 	var queryName string
 	var queryValue string
 
@@ -138,7 +167,7 @@ func (rv *RequestsValidator) populateFromSource(request HTTPRequest, source func
 		fieldType := t.Field(i)
 
 		if !fieldType.Anonymous && fieldValue.IsValid() && fieldValue.CanSet() {
-			tag := fieldType.Tag.Get(queryTag)
+			tag := fieldType.Tag.Get(tagName)
 
 			if tag != "" {
 				queryName = tag
@@ -175,22 +204,29 @@ func (rv *RequestsValidator) populateFromSource(request HTTPRequest, source func
 }
 
 // If user want's only JSON.
-func (rv *RequestsValidator) wantsJSON(ctx *iris.Context) bool {
-	return strings.Contains(ctx.RequestHeader("accept"), "application/json")
+func (rv *RequestsValidator) wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
 }
 
 // Add validation errors to flash and send back 302 redirect.
-func (rv *RequestsValidator) sendWebError(context *Context, ctx *iris.Context) {
-	errors := rv.convertErrors(context)
+func (rv *RequestsValidator) sendWebError(context *Context, ctx iris.Context) {
+	c := adapter.FromIris(ctx)
 
-	ctx.Session().SetFlash("_errors", errors)
-	ctx.Session().SetFlash("_old_input", context.Request)
+	if session := c.Session(); session != nil {
+		session.SetFlash("_errors", rv.convertErrors(context))
+		session.SetFlash("_old_input", context.Request)
+	}
 
-	rv.redirectBack(ctx)
+	rv.redirectBack(c)
 }
 
 // Send API validation error.
-func (rv *RequestsValidator) sendAPIError(context *Context, ctx *iris.Context) {
+func (rv *RequestsValidator) sendAPIError(context *Context, ctx iris.Context) {
+	panic(rv.validationFailed(context))
+}
+
+// Make validation failed APIError with request errors.
+func (rv *RequestsValidator) validationFailed(context *Context) *apierr.APIError {
 	errors := rv.convertErrors(context)
 
 	// Create new api error and attach meta with errors.
@@ -200,36 +236,46 @@ func (rv *RequestsValidator) sendAPIError(context *Context, ctx *iris.Context) {
 	})
 	fail.AddContext(errors)
 
-	panic(&fail)
+	return &fail
 }
 
 // Default bad request callback.
-func (rv *RequestsValidator) sendBadRequest(context *Context, ctx *iris.Context) {
+func (rv *RequestsValidator) sendBadRequest(context *Context, ctx iris.Context) {
 	panic(context.Errors)
 }
 
 // Store current url to reuse it for
-func (rv *RequestsValidator) storeCurrentURL(ctx *iris.Context) {
-	if ctx.Method() == "GET" && !ctx.IsAjax() && !rv.wantsJSON(ctx) {
-		ctx.Session().Set("_previous_url", ctx.Request.URL.String())
+func (rv *RequestsValidator) storeCurrentURL(c adapter.Context) {
+	r := c.Request()
+	session := c.Session()
+
+	if session != nil && r.Method == "GET" && !rv.isAjax(r) && !rv.wantsJSON(r) {
+		session.Set("_previous_url", r.URL.String())
 	}
 }
 
+// If request was made by XMLHttpRequest.
+func (rv *RequestsValidator) isAjax(r *http.Request) bool {
+	return r.Header.Get("X-Requested-With") == "XMLHttpRequest"
+}
+
 // Redirect user back to show page with errors.
-func (rv *RequestsValidator) redirectBack(ctx *iris.Context) {
-	referer := ctx.RequestHeader("referer")
+func (rv *RequestsValidator) redirectBack(c adapter.Context) {
+	referer := c.Request().Header.Get("Referer")
 	if referer != "" {
-		ctx.Redirect(referer, 302)
+		c.Redirect(referer, 302)
 		return
 	}
 
-	previousURL := ctx.Session().GetFlash("_previous_url")
-	if previousURL != nil {
-		ctx.Redirect(previousURL.(string), 302)
-		return
+	if session := c.Session(); session != nil {
+		previousURL := session.GetFlash("_previous_url")
+		if previousURL != nil {
+			c.Redirect(previousURL.(string), 302)
+			return
+		}
 	}
 
-	ctx.Redirect("/", 302)
+	c.Redirect("/", 302)
 }
 
 // Convert errors to field - message format.
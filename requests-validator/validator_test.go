@@ -1,13 +1,16 @@
 package validator_test
 
 import (
+	"strings"
 	"testing"
 
 	validation "github.com/go-ozzo/ozzo-validation"
-	"github.com/kataras/iris"
-	"github.com/kataras/iris/httptest"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	"github.com/kataras/iris/v12/sessions"
 	apierr "github.com/mlanin/go-apierr"
 	handler "github.com/mlanin/iris-middlewares/apierr-handler"
+	"github.com/mlanin/iris-middlewares/apierr-handler/apierrtest"
 	validator "github.com/mlanin/iris-middlewares/requests-validator"
 )
 
@@ -35,6 +38,18 @@ func (r *PostNewsForm) Validate() error {
 	)
 }
 
+type PostNewsTagsForm struct {
+	Text   string   `form:"text"`
+	Tags   []string `form:"tags"`
+	Rating float64  `form:"rating"`
+}
+
+func (r *PostNewsTagsForm) Validate() error {
+	return validation.ValidateStruct(r,
+		validation.Field(&r.Text, validation.Required),
+	)
+}
+
 // PostNewsQuery request.
 type PostNewsQuery struct {
 	Text    string `query:"text"`
@@ -50,89 +65,42 @@ func (r *PostNewsQuery) Validate() error {
 
 func TestItThrowsAPIError(t *testing.T) {
 	api := iris.New()
-	defer api.Close()
 
 	rv := validator.New(validator.Config{})
-	errorsHandler := handler.New(handler.Config{
-		EnvGetter: func() string {
-			return "production"
-		},
-		DebugGetter: func() bool {
-			return false
-		},
-	})
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
 
-	api.Use(errorsHandler)
-	api.Use(rv)
+	api.Use(errorsHandler.Serve)
+	api.Use(rv.Serve)
 
-	api.Post("/news", rv.ValidateRequest(&PostNewsJSON{}), func(ctx *iris.Context) {
-		ctx.Text(200, "Done")
+	api.Post("/news", rv.ValidateRequest(&PostNewsJSON{}), func(ctx iris.Context) {
+		ctx.WriteString("Done")
 	})
 
-	schema := `{
-		"type": "object",
-		"properties": {
-			"error": {
-				"type": "object",
-				"properties": {
-					"id":        {"type": "string"},
-					"message":   {"type": "string"}
-				},
-				"required": ["id", "message"]
-			},
-			"meta": {
-				"type": "object",
-				"properties": {
-					"errors":  {
-            "type": "array",
-            "items": {
-              "type": "object",
-              "properties": {
-                "field":     {"type": "string"},
-                "message":   {"type": "string"}
-              },
-              "required": ["field", "message"]
-            }
-          }
-				},
-				"required": ["errors"]
-			}
-		},
-		"required": ["error", "meta"]
-	}`
+	e := httptest.New(t, api)
+	resp := e.POST("/news").WithHeader("Accept", "application/json").WithJSON(map[string]interface{}{"foo": 123}).
+		Expect()
 
-	e := httptest.New(api, t, httptest.ExplicitURL(true))
-	e.POST("/news").WithHeader("Accept", "application/json").WithJSON(map[string]interface{}{"foo": 123}).
-		Expect().
-		Status(iris.StatusUnprocessableEntity).
-		JSON().Schema(schema)
+	apierrtest.AssertValidationError(t, resp, "text", "Cannot be blank")
 }
 
 func TestItHandlesWebReqest(t *testing.T) {
 	api := iris.New()
-	defer api.Close()
 
 	rv := validator.New(validator.Config{})
-	errorsHandler := handler.New(handler.Config{
-		EnvGetter: func() string {
-			return "production"
-		},
-		DebugGetter: func() bool {
-			return false
-		},
-	})
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
 
-	api.Use(errorsHandler)
-	api.Use(rv)
+	api.Use(errorsHandler.Serve)
+	api.Use(sessions.New(sessions.Config{Cookie: "session"}).Handler())
+	api.Use(rv.Serve)
 
-	api.Get("/foo", func(ctx *iris.Context) {
-		ctx.Text(200, "Foo")
+	api.Get("/foo", func(ctx iris.Context) {
+		ctx.WriteString("Foo")
 	})
-	api.Post("/news", rv.ValidateRequest(&PostNewsForm{}), func(ctx *iris.Context) {
-		ctx.Text(200, "Done")
+	api.Post("/news", rv.ValidateRequest(&PostNewsForm{}), func(ctx iris.Context) {
+		ctx.WriteString("Done")
 	})
 
-	e := httptest.New(api, t, httptest.ExplicitURL(true), httptest.Debug(true))
+	e := httptest.New(t, api)
 	e.POST("/news").WithHeader("Referer", "/foo").
 		Expect().
 		Status(iris.StatusOK).
@@ -141,58 +109,64 @@ func TestItHandlesWebReqest(t *testing.T) {
 
 func TestItPassesIfEverythingIsOk(t *testing.T) {
 	api := iris.New()
-	defer api.Close()
 
 	rv := validator.New(validator.Config{})
-	errorsHandler := handler.New(handler.Config{
-		EnvGetter: func() string {
-			return "production"
-		},
-		DebugGetter: func() bool {
-			return false
-		},
-	})
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
 
-	api.Use(errorsHandler)
-	api.Use(rv)
+	api.Use(errorsHandler.Serve)
+	api.Use(rv.Serve)
 
-	api.Post("/news", rv.ValidateRequest(&PostNewsJSON{}), func(ctx *iris.Context) {
-		request := ctx.Get("validator_test.PostNewsJSON").(*PostNewsJSON)
+	api.Post("/news", rv.ValidateRequest(&PostNewsJSON{}), func(ctx iris.Context) {
+		request := ctx.Values().Get("validator_test.PostNewsJSON").(*PostNewsJSON)
 
-		ctx.Text(200, request.Text)
+		ctx.WriteString(request.Text)
 	})
 
-	e := httptest.New(api, t, httptest.ExplicitURL(true))
+	e := httptest.New(t, api)
 	e.POST("/news").WithJSON(&News{Text: "Foo bar"}).
 		Expect().
 		Status(iris.StatusOK).
 		Body().Equal("Foo bar")
 }
 
-func TestItPassesQueryIfEverythingIsOk(t *testing.T) {
+func TestItPassesFormWithAnyFields(t *testing.T) {
 	api := iris.New()
-	defer api.Close()
 
 	rv := validator.New(validator.Config{})
-	errorsHandler := handler.New(handler.Config{
-		EnvGetter: func() string {
-			return "production"
-		},
-		DebugGetter: func() bool {
-			return false
-		},
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
+
+	api.Use(errorsHandler.Serve)
+	api.Use(rv.Serve)
+
+	api.Post("/news", rv.ValidateRequest(&PostNewsTagsForm{}), func(ctx iris.Context) {
+		request := ctx.Values().Get("validator_test.PostNewsTagsForm").(*PostNewsTagsForm)
+
+		ctx.Writef("%s %s %.1f", request.Text, strings.Join(request.Tags, ","), request.Rating)
 	})
 
-	api.Use(errorsHandler)
-	api.Use(rv)
+	e := httptest.New(t, api)
+	e.POST("/news").WithFormField("text", "Foo").WithFormField("tags", "a").WithFormField("tags", "b").WithFormField("rating", "4.5").
+		Expect().
+		Status(iris.StatusOK).
+		Body().Equal("Foo a,b 4.5")
+}
+
+func TestItPassesQueryIfEverythingIsOk(t *testing.T) {
+	api := iris.New()
+
+	rv := validator.New(validator.Config{})
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
 
-	api.Get("/news", rv.ValidateRequest(&PostNewsQuery{}), func(ctx *iris.Context) {
-		request := ctx.Get("validator_test.PostNewsQuery").(*PostNewsQuery)
+	api.Use(errorsHandler.Serve)
+	api.Use(rv.Serve)
 
-		ctx.Text(200, request.Text)
+	api.Get("/news", rv.ValidateRequest(&PostNewsQuery{}), func(ctx iris.Context) {
+		request := ctx.Values().Get("validator_test.PostNewsQuery").(*PostNewsQuery)
+
+		ctx.WriteString(request.Text)
 	})
 
-	e := httptest.New(api, t, httptest.ExplicitURL(true))
+	e := httptest.New(t, api)
 	e.GET("/news").WithHeader("Accept", "application/json").WithQuery("text", "Foo bar").WithQuery("int", 123).
 		Expect().
 		Status(iris.StatusOK).
@@ -201,31 +175,23 @@ func TestItPassesQueryIfEverythingIsOk(t *testing.T) {
 
 func TestHandlerOverride(t *testing.T) {
 	api := iris.New()
-	defer api.Close()
 
-	errorsHandler := handler.New(handler.Config{
-		EnvGetter: func() string {
-			return "production"
-		},
-		DebugGetter: func() bool {
-			return false
-		},
-	})
+	errorsHandler := apierrtest.NewHandler(t, handler.Config{})
 
 	rv := validator.New(validator.Config{
-		APIHandler: func(context *validator.Context, ctx *iris.Context) {
+		APIHandler: func(context *validator.Context, ctx iris.Context) {
 			panic(apierr.NotFound)
 		},
 	})
 
-	api.Use(errorsHandler)
-	api.Use(rv)
+	api.Use(errorsHandler.Serve)
+	api.Use(rv.Serve)
 
-	api.Post("/news", rv.ValidateRequest(&PostNewsJSON{}), func(ctx *iris.Context) {
-		ctx.Text(200, "Done")
+	api.Post("/news", rv.ValidateRequest(&PostNewsJSON{}), func(ctx iris.Context) {
+		ctx.WriteString("Done")
 	})
 
-	e := httptest.New(api, t, httptest.ExplicitURL(true))
+	e := httptest.New(t, api)
 	e.POST("/news").WithHeader("Accept", "application/json").WithJSON(map[string]interface{}{"foo": 123}).
 		Expect().
 		Status(iris.StatusNotFound)